@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// allowedTables parses MSSQL_ALLOWED_TABLES, a comma-separated list of
+// schema-qualified tables, into a set of lowercase schema.table names. It
+// returns nil when the variable is unset, meaning every table is allowed.
+// Unqualified entries are taken to be in the default schema.
+func allowedTables() map[string]bool {
+	raw := strings.TrimSpace(os.Getenv("MSSQL_ALLOWED_TABLES"))
+	if raw == "" {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		schema, name := splitTableName(strings.TrimSpace(entry))
+		if name == "" {
+			continue
+		}
+		allowed[qualifiedTableKey(schema, name)] = true
+	}
+	return allowed
+}
+
+// qualifiedTableKey returns the lowercase schema.table key tables are
+// compared by. An empty schema means MSSQL_DEFAULT_SCHEMA, or dbo when that is
+// unset, which is where SQL Server looks for unqualified names by default.
+func qualifiedTableKey(schema, name string) string {
+	if schema == "" {
+		schema = defaultSchema()
+	}
+	if schema == "" {
+		schema = "dbo"
+	}
+	return strings.ToLower(schema + "." + name)
+}
+
+// checkTableAllowed rejects a table resolved against the catalog that is not
+// in MSSQL_ALLOWED_TABLES.
+func checkTableAllowed(schema, name string) error {
+	allowed := allowedTables()
+	if allowed == nil || allowed[qualifiedTableKey(schema, name)] {
+		return nil
+	}
+	return fmt.Errorf("table '%s.%s' is not in MSSQL_ALLOWED_TABLES", schema, name)
+}
+
+// tableRefKeywords are the keywords a table reference follows.
+var tableRefKeywords = map[string]bool{
+	"from":   true,
+	"join":   true,
+	"apply":  true,
+	"update": true,
+	"into":   true,
+	"insert": true,
+	"delete": true,
+	"merge":  true,
+	"using":  true,
+	"table":  true,
+}
+
+// aliasStopWords may follow a table reference but are never its alias.
+var aliasStopWords = map[string]bool{
+	"where": true, "join": true, "inner": true, "left": true, "right": true, "full": true,
+	"cross": true, "outer": true, "on": true, "group": true, "order": true, "having": true,
+	"union": true, "except": true, "intersect": true, "set": true, "values": true,
+	"select": true, "output": true, "option": true, "for": true, "when": true, "using": true,
+	"with": true, "default": true, "exec": true, "execute": true, "insert": true,
+	"update": true, "delete": true, "merge": true, "from": true, "into": true, "go": true,
+	"pivot": true, "unpivot": true, "tablesample": true, "begin": true, "end": true,
+	"if": true, "else": true, "while": true, "declare": true, "drop": true, "create": true,
+	"alter": true, "truncate": true, "return": true, "commit": true, "rollback": true,
+}
+
+// dynamicSQLTokens run SQL the allowlist cannot see into.
+var dynamicSQLTokens = map[string]bool{
+	"exec":          true,
+	"execute":       true,
+	"sp_executesql": true,
+}
+
+// isIdentifierToken reports whether token from sqlTokensWithSymbols can be
+// part of an object name.
+func isIdentifierToken(token string) bool {
+	if strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]") && len(token) >= 2 {
+		return true
+	}
+	return token != "" && isWordRune([]rune(token)[0])
+}
+
+// identifierText strips the brackets sqlTokensWithSymbols puts around quoted
+// identifiers.
+func identifierText(token string) string {
+	if strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]") && len(token) >= 2 {
+		return token[1 : len(token)-1]
+	}
+	return token
+}
+
+// skipParens returns the index just past the parenthesis group opening at
+// tokens[i].
+func skipParens(tokens []string, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// queryTableRefs extracts the tables query reads or writes from the names
+// after FROM, JOIN, UPDATE, INTO and similar keywords. Temp tables, table
+// variables and CTE names are left out; a CTE name only counts as such up to
+// the next semicolon, since it is scoped to a single statement. Aliases are
+// not resolved, so UPDATE and DELETE must name their target table. Each
+// reference is returned as its name parts, e.g. ["sales", "orders"].
+func queryTableRefs(tokens []string) [][]string {
+	// ctes maps each CTE name to the token range of its statement.
+	ctes := make(map[string][2]int)
+	for i := 0; i+2 < len(tokens); i++ {
+		// WITH name [(columns)] AS ( ... ) declares a CTE.
+		if tokens[i] != "with" && tokens[i] != "," || !isIdentifierToken(tokens[i+1]) {
+			continue
+		}
+		j := i + 2
+		if tokens[j] == "(" {
+			j = skipParens(tokens, j)
+		}
+		if j+1 < len(tokens) && tokens[j] == "as" && tokens[j+1] == "(" {
+			end := j
+			for end < len(tokens) && tokens[end] != ";" {
+				end++
+			}
+			ctes[identifierText(tokens[i+1])] = [2]int{i, end}
+		}
+	}
+
+	var refs [][]string
+	for i := 0; i < len(tokens); i++ {
+		keyword := tokens[i]
+		if !tableRefKeywords[keyword] {
+			continue
+		}
+		for j := i + 1; j < len(tokens); {
+			token := tokens[j]
+			if token == "top" && j+1 < len(tokens) && tokens[j+1] == "(" {
+				// UPDATE TOP (n) table ...
+				j = skipParens(tokens, j+1)
+				continue
+			}
+			if token == "if" && j+1 < len(tokens) && tokens[j+1] == "exists" {
+				// DROP TABLE IF EXISTS table
+				j += 2
+				continue
+			}
+			if !isIdentifierToken(token) || aliasStopWords[token] {
+				break
+			}
+			if strings.HasPrefix(token, "@") || strings.HasPrefix(token, "#") {
+				break
+			}
+
+			parts := []string{identifierText(token)}
+			start := j
+			j++
+			for j < len(tokens) && tokens[j] == "." {
+				j++
+				if j < len(tokens) && isIdentifierToken(tokens[j]) {
+					parts = append(parts, identifierText(tokens[j]))
+					j++
+				} else {
+					// db..table leaves the schema out.
+					parts = append(parts, "")
+				}
+			}
+			if scope, ok := ctes[parts[0]]; !ok || len(parts) > 1 || start < scope[0] || start > scope[1] {
+				refs = append(refs, parts)
+			}
+
+			// [AS] alias, table hints, then a comma before the next table in
+			// an old-style FROM list.
+			if j < len(tokens) && tokens[j] == "as" {
+				j++
+			}
+			if j < len(tokens) && isIdentifierToken(tokens[j]) && !aliasStopWords[tokens[j]] {
+				j++
+			}
+			if j+1 < len(tokens) && tokens[j] == "with" && tokens[j+1] == "(" {
+				j = skipParens(tokens, j+1)
+			}
+			if keyword != "from" || j >= len(tokens) || tokens[j] != "," {
+				break
+			}
+			j++
+		}
+	}
+	return refs
+}
+
+// checkAllowedTables rejects query when MSSQL_ALLOWED_TABLES is set and the
+// query references a table outside it. Dynamic SQL, EXEC and references into
+// other databases, by a three-part name or a USE statement, cannot be checked
+// and are rejected too.
+func checkAllowedTables(query string) error {
+	allowed := allowedTables()
+	if allowed == nil {
+		return nil
+	}
+
+	tokens := sqlTokensWithSymbols(query)
+	for _, token := range tokens {
+		if dynamicSQLTokens[token] {
+			return fmt.Errorf("query rejected: %s cannot be checked against MSSQL_ALLOWED_TABLES", strings.ToUpper(token))
+		}
+		if token == "use" {
+			return fmt.Errorf("query rejected: USE switches to another database, which cannot be checked against MSSQL_ALLOWED_TABLES")
+		}
+	}
+
+	for _, parts := range queryTableRefs(tokens) {
+		name := strings.Join(parts, ".")
+		switch len(parts) {
+		case 1:
+			name = qualifiedTableKey("", parts[0])
+		case 2:
+			name = qualifiedTableKey(parts[0], parts[1])
+		default:
+			return fmt.Errorf("query rejected: '%s' refers to another database, which cannot be checked against MSSQL_ALLOWED_TABLES", name)
+		}
+		if !allowed[name] {
+			return fmt.Errorf("query rejected: table '%s' is not in MSSQL_ALLOWED_TABLES", name)
+		}
+	}
+	return nil
+}
+
+// checkAllowedDatabase rejects running a query in another database, as the
+// execute_sql database argument asks, when MSSQL_ALLOWED_TABLES is set. The
+// allowlist names tables in the connection's own database only.
+func checkAllowedDatabase(database string) error {
+	if database == "" || allowedTables() == nil {
+		return nil
+	}
+	return fmt.Errorf("query rejected: database '%s' cannot be checked against MSSQL_ALLOWED_TABLES, which only covers the connection's own database", database)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAllowedTables(t *testing.T) {
+	t.Setenv("MSSQL_DEFAULT_SCHEMA", "")
+	t.Setenv("MSSQL_ALLOWED_TABLES", "")
+	assert.NoError(t, checkAllowedTables("SELECT * FROM dbo.secrets"))
+
+	t.Setenv("MSSQL_ALLOWED_TABLES", "dbo.orders, sales.[Order Details], customers")
+	allowed := []string{
+		"SELECT * FROM dbo.orders",
+		"SELECT * FROM orders o JOIN dbo.customers c ON c.id = o.customer_id",
+		"SELECT * FROM [sales].[order details] WITH (NOLOCK), dbo.Orders",
+		"SELECT * FROM (SELECT id FROM dbo.orders) AS o",
+		"WITH recent AS (SELECT * FROM dbo.orders) SELECT * FROM recent",
+		"UPDATE TOP (10) dbo.orders SET status = 'x' WHERE id IN (SELECT id FROM customers)",
+		"INSERT INTO dbo.orders (id) SELECT id FROM #staging",
+		"DECLARE @t TABLE (id int); SELECT * FROM @t",
+		"SELECT 'FROM dbo.secrets' AS note, COUNT(*) FROM dbo.orders -- JOIN dbo.secrets",
+		"SELECT 1",
+	}
+	for _, query := range allowed {
+		assert.NoError(t, checkAllowedTables(query), query)
+	}
+
+	rejected := map[string]string{
+		"SELECT * FROM dbo.secrets":                                                    "table 'dbo.secrets' is not in MSSQL_ALLOWED_TABLES",
+		"SELECT * FROM dbo.orders o LEFT JOIN hr.salaries s ON s.id = o.id":            "table 'hr.salaries'",
+		"SELECT * FROM dbo.orders, secrets":                                            "table 'dbo.secrets'",
+		"DELETE FROM dbo.audit WHERE id = 1":                                           "table 'dbo.audit'",
+		"DROP TABLE IF EXISTS dbo.audit":                                               "table 'dbo.audit'",
+		"MERGE INTO dbo.orders AS t USING dbo.imports AS s ON t.id = s.id":             "table 'dbo.imports'",
+		"SELECT * FROM other.dbo.orders":                                               "'other.dbo.orders' refers to another database",
+		"EXEC ('SELECT * FROM dbo.secrets')":                                           "EXEC cannot be checked",
+		"EXEC sp_executesql N'SELECT 1'":                                               "EXEC cannot be checked",
+		"WITH secrets AS (SELECT 1 AS x) SELECT * FROM secrets; SELECT * FROM secrets": "table 'dbo.secrets'",
+		"SELECT * FROM OPENJSON(@doc)":                                                 "table 'dbo.openjson'",
+		"USE OtherTenant; SELECT * FROM dbo.orders":                                    "USE switches to another database",
+	}
+	for query, message := range rejected {
+		assert.ErrorContains(t, checkAllowedTables(query), message, query)
+	}
+}
+
+func TestCheckAllowedDatabase(t *testing.T) {
+	t.Setenv("MSSQL_ALLOWED_TABLES", "")
+	assert.NoError(t, checkAllowedDatabase("OtherTenant"))
+
+	t.Setenv("MSSQL_ALLOWED_TABLES", "dbo.orders")
+	assert.NoError(t, checkAllowedDatabase(""))
+	assert.ErrorContains(t, checkAllowedDatabase("OtherTenant"), "database 'OtherTenant' cannot be checked against MSSQL_ALLOWED_TABLES")
+}
+
+func TestCheckTableAllowed(t *testing.T) {
+	t.Setenv("MSSQL_DEFAULT_SCHEMA", "sales")
+	t.Setenv("MSSQL_ALLOWED_TABLES", "orders")
+	assert.NoError(t, checkTableAllowed("Sales", "Orders"))
+	assert.ErrorContains(t, checkTableAllowed("dbo", "orders"), "table 'dbo.orders' is not in MSSQL_ALLOWED_TABLES")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultAsyncResultTTL is how long a finished async query's output is kept
+// for get_query_result when MSSQL_ASYNC_RESULT_TTL_SECONDS is unset.
+const defaultAsyncResultTTL = time.Hour
+
+// asyncResultTTL returns how long the output of a finished async query is
+// kept before it is dropped uncollected, from MSSQL_ASYNC_RESULT_TTL_SECONDS.
+// Zero keeps results until they are collected.
+func asyncResultTTL() time.Duration {
+	return time.Duration(intEnv("MSSQL_ASYNC_RESULT_TTL_SECONDS", int(defaultAsyncResultTTL/time.Second), 0)) * time.Second
+}
+
+// asyncQuery is a query running in the background on behalf of an
+// execute_sql call made with async=true.
+type asyncQuery struct {
+	cancel  context.CancelFunc
+	started time.Time
+	done    chan struct{}
+
+	// result, err and finished are written once before done is closed.
+	result   string
+	err      error
+	finished time.Time
+}
+
+// startAsyncQuery runs fn in the background and returns the ID it can be
+// collected or cancelled by. The query counts as in flight for shutdown
+// draining until fn returns.
+func (dm *DatabaseManager) startAsyncQuery(fn func(ctx context.Context) (string, error)) string {
+	ctx, cancel := context.WithCancel(dm.shutdownCtx)
+	q := &asyncQuery{cancel: cancel, started: time.Now(), done: make(chan struct{})}
+
+	dm.mu.Lock()
+	dm.nextQueryID++
+	id := fmt.Sprintf("q%d", dm.nextQueryID)
+	dm.queries[id] = q
+	dm.inflight.Add(1)
+	dm.mu.Unlock()
+
+	go func() {
+		defer dm.inflight.Done()
+		defer cancel()
+		q.result, q.err = fn(ctx)
+		q.finished = time.Now()
+		close(q.done)
+	}()
+
+	return id
+}
+
+// asyncQueryResult returns the output of a finished async query and forgets
+// it, or a status message if the query is still running.
+func (dm *DatabaseManager) asyncQueryResult(id string) (string, error) {
+	dm.mu.RLock()
+	q, ok := dm.queries[id]
+	dm.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no async query with ID '%s'", id)
+	}
+
+	select {
+	case <-q.done:
+	default:
+		return fmt.Sprintf("Query %s is still running (started %v ago).", id, time.Since(q.started).Round(time.Second)), nil
+	}
+
+	dm.mu.Lock()
+	delete(dm.queries, id)
+	dm.mu.Unlock()
+
+	return q.result, q.err
+}
+
+// cancelAsyncQuery cancels the context of a running async query. The query
+// stays registered so its cancellation error can still be collected.
+func (dm *DatabaseManager) cancelAsyncQuery(id string) error {
+	dm.mu.RLock()
+	q, ok := dm.queries[id]
+	dm.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no async query with ID '%s'", id)
+	}
+
+	select {
+	case <-q.done:
+		return fmt.Errorf("query %s has already finished", id)
+	default:
+	}
+
+	q.cancel()
+	return nil
+}
+
+// evictAsyncResults forgets async queries that finished more than ttl ago
+// without their output being collected, so abandoned results do not pile up.
+// Running queries are kept however long they take.
+func (dm *DatabaseManager) evictAsyncResults(ttl time.Duration) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for id, q := range dm.queries {
+		select {
+		case <-q.done:
+		default:
+			continue
+		}
+		if time.Since(q.finished) >= ttl {
+			delete(dm.queries, id)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncQueryCancel(t *testing.T) {
+	dm := NewDatabaseManager()
+	started := make(chan struct{})
+
+	id := dm.startAsyncQuery(func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	<-started
+
+	status, err := dm.asyncQueryResult(id)
+	require.NoError(t, err)
+	assert.Contains(t, status, "still running")
+
+	require.NoError(t, dm.cancelAsyncQuery(id))
+	assert.Eventually(t, func() bool {
+		_, err := dm.asyncQueryResult(id)
+		return err == context.Canceled
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = dm.asyncQueryResult(id)
+	assert.ErrorContains(t, err, "no async query")
+}
+
+func TestAsyncQueryResult(t *testing.T) {
+	dm := NewDatabaseManager()
+
+	id := dm.startAsyncQuery(func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+
+	assert.Eventually(t, func() bool {
+		result, err := dm.asyncQueryResult(id)
+		return err == nil && result == "done"
+	}, time.Second, 10*time.Millisecond)
+	assert.ErrorContains(t, dm.cancelAsyncQuery(id), "no async query")
+}
+
+func TestEvictAsyncResults(t *testing.T) {
+	dm := NewDatabaseManager()
+	release := make(chan struct{})
+	running := dm.startAsyncQuery(func(ctx context.Context) (string, error) {
+		<-release
+		return "late", nil
+	})
+	finished := dm.startAsyncQuery(func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+	<-dm.queries[finished].done
+
+	dm.evictAsyncResults(time.Hour)
+	assert.Len(t, dm.queries, 2, "results younger than the TTL are kept")
+
+	dm.queries[finished].finished = time.Now().Add(-2 * time.Hour)
+	dm.evictAsyncResults(time.Hour)
+	_, err := dm.asyncQueryResult(finished)
+	assert.ErrorContains(t, err, "no async query")
+	status, err := dm.asyncQueryResult(running)
+	require.NoError(t, err)
+	assert.Contains(t, status, "still running", "running queries are never evicted")
+
+	close(release)
+	dm.inflight.Wait()
+}
+
+func TestAsyncResultTTL(t *testing.T) {
+	t.Setenv("MSSQL_ASYNC_RESULT_TTL_SECONDS", "")
+	assert.Equal(t, time.Hour, asyncResultTTL())
+	t.Setenv("MSSQL_ASYNC_RESULT_TTL_SECONDS", "90")
+	assert.Equal(t, 90*time.Second, asyncResultTTL())
+	t.Setenv("MSSQL_ASYNC_RESULT_TTL_SECONDS", "0")
+	assert.Zero(t, asyncResultTTL())
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// auditLog appends one JSON line per tool call to the file named by
+// MSSQL_AUDIT_LOG. It never writes to stdout, which carries the MCP stream
+// in stdio mode.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// closer is the underlying file, if any.
+	closer io.Closer
+}
+
+// auditEntry is the JSON shape of one audit log line.
+type auditEntry struct {
+	Time       string                 `json:"time"`
+	Tool       string                 `json:"tool"`
+	Arguments  map[string]interface{} `json:"arguments,omitempty"`
+	Success    bool                   `json:"success"`
+	DurationMS int64                  `json:"duration_ms"`
+	Result     string                 `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// openAuditLog opens the MSSQL_AUDIT_LOG file for appending, creating it with
+// owner-only permissions. It returns nil when the variable is unset.
+func openAuditLog() (*auditLog, error) {
+	path := strings.TrimSpace(os.Getenv("MSSQL_AUDIT_LOG"))
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MSSQL_AUDIT_LOG: %v", err)
+	}
+	return &auditLog{w: file, closer: file}, nil
+}
+
+// Close closes the underlying file.
+func (a *auditLog) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// toolCallSucceeded reports whether a tool call worked. Handlers report
+// query failures as ordinary text starting with "Error:" or, in JSON format,
+// as an {"error": ...} object, so those count as failures too.
+func toolCallSucceeded(result *mcp.CallToolResult, err error) bool {
+	if err != nil || result == nil || result.IsError {
+		return false
+	}
+	text := strings.TrimSpace(toolResultText(result))
+	return !strings.HasPrefix(text, "Error:") && !strings.HasPrefix(text, `{"error":`)
+}
+
+// toolResultText joins the text content of result.
+func toolResultText(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// middleware is tool middleware that records every call with its arguments,
+// outcome and result. A failure to write the log is reported on stderr and
+// does not affect the call.
+func (a *auditLog) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started := time.Now()
+		result, err := next(ctx, request)
+
+		entry := auditEntry{
+			Time:       started.UTC().Format(time.RFC3339Nano),
+			Tool:       request.Params.Name,
+			Arguments:  request.GetArguments(),
+			Success:    toolCallSucceeded(result, err),
+			DurationMS: time.Since(started).Milliseconds(),
+			Result:     toolResultText(result),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		a.write(entry)
+
+		return result, err
+	}
+}
+
+func (a *auditLog) write(entry auditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to encode audit log entry: %v\n", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	audit := &auditLog{w: &buf}
+
+	handler := audit.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if request.GetString("query", "") == "bad" {
+			return mcp.NewToolResultText("Error: query execution failed"), nil
+		}
+		return mcp.NewToolResultText("id\n--\n1"), nil
+	})
+
+	for _, query := range []string{"SELECT 1", "bad"} {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "execute_sql"
+		request.Params.Arguments = map[string]interface{}{"query": query}
+		_, err := handler(context.Background(), request)
+		require.NoError(t, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var first, second auditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, "execute_sql", first.Tool)
+	assert.Equal(t, "SELECT 1", first.Arguments["query"])
+	assert.True(t, first.Success)
+	assert.Equal(t, "id\n--\n1", first.Result)
+	assert.NotEmpty(t, first.Time)
+	assert.False(t, second.Success)
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	t.Setenv("MSSQL_AUDIT_LOG", "")
+	audit, err := openAuditLog()
+	require.NoError(t, err)
+	assert.Nil(t, audit)
+	assert.NoError(t, audit.Close())
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o600))
+	t.Setenv("MSSQL_AUDIT_LOG", path)
+	audit, err = openAuditLog()
+	require.NoError(t, err)
+	audit.write(auditEntry{Tool: "list_tables", Success: true})
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n{\"time\":\"\",\"tool\":\"list_tables\",\"success\":true,\"duration_ms\":0}\n", string(data))
+
+	t.Setenv("MSSQL_AUDIT_LOG", filepath.Join(t.TempDir(), "missing", "audit.jsonl"))
+	_, err = openAuditLog()
+	assert.ErrorContains(t, err, "MSSQL_AUDIT_LOG")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// Authentication modes selectable with MSSQL_AUTH.
+const (
+	authPassword = "password"
+	authAzureAD  = "azure-ad"
+)
+
+const (
+	azureSQLScope     = "https://database.windows.net/.default"
+	azureTokenTimeout = 30 * time.Second
+)
+
+// openDB opens a pool for connString using the authentication mode from
+// MSSQL_AUTH. The default relies on the credentials in the connection string.
+// The application name from MSSQL_APP_NAME is added in either mode, and
+// encryption is enforced when MSSQL_REQUIRE_ENCRYPTION is set.
+func openDB(connString string) (*sql.DB, error) {
+	connString, err := withRequiredEncryption(withAppName(connString))
+	if err != nil {
+		return nil, err
+	}
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("MSSQL_AUTH")))
+	switch mode {
+	case "", authPassword:
+		return sql.Open("sqlserver", connString)
+	case authAzureAD:
+		return openAzureADDB(connString)
+	}
+	return nil, fmt.Errorf("unsupported MSSQL_AUTH '%s': expected %s or %s", mode, authPassword, authAzureAD)
+}
+
+// openAzureADDB opens a pool that authenticates with an Entra ID access token
+// instead of a password. DefaultAzureCredential covers managed identity,
+// workload identity, environment credentials and the Azure CLI. The token
+// provider runs for every new physical connection, and the credential caches
+// the token and refreshes it before it expires.
+func openAzureADDB(connString string) (*sql.DB, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+	}
+
+	connector, err := mssql.NewAccessTokenConnector(connString, func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), azureTokenTimeout)
+		defer cancel()
+
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureSQLScope}})
+		if err != nil {
+			return "", fmt.Errorf("failed to acquire Azure AD access token: %v", err)
+		}
+		return token.Token, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(connector), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// autoTopEnabled reports whether MSSQL_AUTO_TOP asks for row limits to be
+// pushed into execute_sql queries as TOP clauses.
+func autoTopEnabled() bool {
+	return boolEnv("MSSQL_AUTO_TOP")
+}
+
+// withAutoTop rewrites a single SELECT that has no TOP or OFFSET to start with
+// TOP (maxRows + 1), so the server stops after the rows the client would keep
+// instead of producing every row for the client to discard. The extra row
+// lets the output still be marked as truncated. DISTINCT and ALL are kept
+// before the TOP, and leading CTEs are skipped to reach the main SELECT.
+// Anything the rewrite cannot be sure of is returned unchanged: batches with
+// several statements, set operations such as UNION at the top level,
+// SELECT ... INTO, variable assignments and statements other than SELECT.
+func withAutoTop(query string, maxRows int) string {
+	if maxRows <= 0 {
+		return query
+	}
+	tokens := scanSQLTokenSpans(query, true)
+	for len(tokens) > 0 && tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	i := skipCTEs(tokens)
+	if i < 0 || i >= len(tokens) || tokens[i].text != "select" {
+		return query
+	}
+	selectAt := i
+
+	// Only the main SELECT's own clauses count, so keywords inside
+	// subqueries and function calls are skipped over.
+	for j := selectAt + 1; j < len(tokens); {
+		switch tokens[j].text {
+		case "(":
+			j = skipSpanParens(tokens, j)
+			continue
+		case ")", ";", "select", "top", "offset", "into", "union", "except", "intersect":
+			return query
+		}
+		if isVariableAssignment(tokens, j) {
+			return query
+		}
+		j++
+	}
+
+	insertAt := selectAt
+	if next := selectAt + 1; next < len(tokens) && (tokens[next].text == "distinct" || tokens[next].text == "all") {
+		insertAt = next
+	}
+	runes := []rune(query)
+	end := tokens[insertAt].end
+	return string(runes[:end]) + fmt.Sprintf(" TOP (%d)", maxRows+1) + string(runes[end:])
+}
+
+// skipCTEs returns the index of the statement that follows a leading WITH
+// clause, or 0 when tokens do not start with one. It returns -1 when the
+// clause is not of the form WITH name [(columns)] AS (...) [, ...].
+func skipCTEs(tokens []sqlTokenSpan) int {
+	if len(tokens) == 0 || tokens[0].text != "with" {
+		return 0
+	}
+	i := 1
+	for {
+		if i >= len(tokens) || !isIdentifierToken(tokens[i].text) {
+			return -1
+		}
+		i++
+		if i < len(tokens) && tokens[i].text == "(" {
+			i = skipSpanParens(tokens, i)
+		}
+		if i+1 >= len(tokens) || tokens[i].text != "as" || tokens[i+1].text != "(" {
+			return -1
+		}
+		i = skipSpanParens(tokens, i+1)
+		if i < len(tokens) && tokens[i].text == "," {
+			i++
+			continue
+		}
+		return i
+	}
+}
+
+// skipSpanParens is skipParens for token spans.
+func skipSpanParens(tokens []sqlTokenSpan, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// isVariableAssignment reports whether the @variable at tokens[i] is assigned
+// to, as in SELECT @total = SUM(amount) or @n += 1. Such a SELECT keeps the
+// value from its last row, which a TOP would change.
+func isVariableAssignment(tokens []sqlTokenSpan, i int) bool {
+	if !strings.HasPrefix(tokens[i].text, "@") || i+1 >= len(tokens) {
+		return false
+	}
+	next := tokens[i+1].text
+	if next == "=" {
+		return true
+	}
+	return strings.Contains("+-*/%&|^", next) && i+2 < len(tokens) && tokens[i+2].text == "="
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithAutoTop(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM dbo.orders":                                                                         "SELECT TOP (101) * FROM dbo.orders",
+		"select distinct region from dbo.orders;":                                                          "select distinct TOP (101) region from dbo.orders;",
+		"SELECT ALL id FROM t":                                                                             "SELECT ALL TOP (101) id FROM t",
+		"-- recent orders\nSELECT id FROM t ORDER BY id":                                                   "-- recent orders\nSELECT TOP (101) id FROM t ORDER BY id",
+		"SELECT id FROM t WHERE id IN (SELECT TOP 5 id FROM u)":                                            "SELECT TOP (101) id FROM t WHERE id IN (SELECT TOP 5 id FROM u)",
+		"SELECT COUNT(*) AS n, N'top' AS [select] FROM t":                                                  "SELECT TOP (101) COUNT(*) AS n, N'top' AS [select] FROM t",
+		"WITH recent AS (SELECT * FROM t) SELECT * FROM recent":                                            "WITH recent AS (SELECT * FROM t) SELECT TOP (101) * FROM recent",
+		"WITH a (id) AS (SELECT 1 UNION ALL SELECT 2), b AS (SELECT id FROM a)\nSELECT DISTINCT id FROM b": "WITH a (id) AS (SELECT 1 UNION ALL SELECT 2), b AS (SELECT id FROM a)\nSELECT DISTINCT TOP (101) id FROM b",
+	}
+	for query, want := range cases {
+		assert.Equal(t, want, withAutoTop(query, 100), query)
+	}
+}
+
+func TestWithAutoTopLeavesQueryAlone(t *testing.T) {
+	for _, query := range []string{
+		"SELECT TOP 10 * FROM t",
+		"SELECT DISTINCT TOP (5) id FROM t",
+		"SELECT id FROM t ORDER BY id OFFSET 10 ROWS FETCH NEXT 10 ROWS ONLY",
+		"SELECT id FROM t UNION SELECT id FROM u",
+		"WITH c AS (SELECT 1 AS id) SELECT id FROM c EXCEPT SELECT id FROM u",
+		"SELECT 1; SELECT 2",
+		"SELECT 1 SELECT 2",
+		"SELECT * INTO #copy FROM t",
+		"SELECT @total = SUM(amount) FROM t",
+		"SELECT @n += 1 FROM t",
+		"WITH c AS (SELECT id FROM t) DELETE FROM c",
+		"WITH c AS SELECT 1",
+		"UPDATE t SET x = 1",
+		"EXEC dbo.report",
+	} {
+		assert.Equal(t, query, withAutoTop(query, 100), query)
+	}
+
+	assert.Equal(t, "SELECT * FROM t", withAutoTop("SELECT * FROM t", 0))
+	// Parameters compared with = are not assignments.
+	assert.Equal(t, "SELECT TOP (11) id FROM t WHERE id = @p1", withAutoTop("SELECT id FROM t WHERE id = @p1", 10))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// batchHeader labels the output of one query in an execute_batch or
+// execute_script call, e.g. "-- Query 2 --".
+func batchHeader(label string, index int) string {
+	return fmt.Sprintf("-- %s %d --\n", label, index)
+}
+
+// statementTimeout is the share of the remaining batch budget one statement
+// may use when left statements, including it, are still to run. Time the
+// earlier statements did not need carries over to the later ones.
+func statementTimeout(remaining time.Duration, left int) time.Duration {
+	return remaining / time.Duration(max(left, 1))
+}
+
+// executeBatch runs queries one after another on a single reserved
+// connection, so session state such as temp tables carries over between
+// them. Each query's output or error is placed under a header naming label
+// and its 1-based position. A failure is reported and the batch continues
+// unless stopOnError is set.
+//
+// The batch runs within budget, which defaults to the query timeout for each
+// query. Every query gets an equal share of what is left of it, so a hung
+// statement times out on its own instead of using up the time the rest were
+// meant to have.
+func executeBatch(ctx context.Context, dm *DatabaseManager, opts queryOptions, queries []string, label, format string, stopOnError bool, budget time.Duration) (string, error) {
+	if err := validateFormat(format); err != nil {
+		return "", err
+	}
+	if budget <= 0 {
+		budget = queryTimeout() * time.Duration(len(queries))
+	}
+
+	db, err := dm.getConnection(opts.connection)
+	if err != nil {
+		return "", fmt.Errorf("database connection unavailable: %v", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to reserve a connection: %v", err)
+	}
+	defer conn.Close()
+	opts.conn = conn
+	deadline := time.Now().Add(budget)
+
+	var output strings.Builder
+	for i, query := range queries {
+		if i > 0 {
+			output.WriteString("\n\n")
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			fmt.Fprintf(&output, "Stopped before %s %d: the %v time budget was used up; %d remaining were not run.", strings.ToLower(label), i+1, budget, len(queries)-i)
+			break
+		}
+		output.WriteString(batchHeader(label, i+1))
+
+		err := checkQuery(query)
+		if err == nil {
+			opts := opts
+			opts.timeout = statementTimeout(remaining, len(queries)-i).Round(time.Millisecond)
+			stmtCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+			var result string
+			result, err = instrumentQuery(query, func(ctx context.Context) (string, error) {
+				return executeQuery(ctx, dm, opts, query, nil, format)
+			})(stmtCtx)
+			timedOut := errors.Is(stmtCtx.Err(), context.DeadlineExceeded)
+			cancel()
+			if err == nil {
+				output.WriteString(result)
+				continue
+			}
+			if timedOut {
+				err = fmt.Errorf("%s %d timed out after %v, its share of the %v batch budget: %v", strings.ToLower(label), i+1, opts.timeout, budget, err)
+			}
+		}
+
+		fmt.Fprintf(&output, "Error: %v", err)
+		if stopOnError && i < len(queries)-1 {
+			fmt.Fprintf(&output, "\n\nStopped after %s %d failed; %d remaining were not run.", strings.ToLower(label), i+1, len(queries)-i-1)
+			break
+		}
+	}
+
+	return output.String(), nil
+}
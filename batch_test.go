@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	assert.Equal(t, 10*time.Second, statementTimeout(30*time.Second, 3))
+	// The last statement may use everything that is left.
+	assert.Equal(t, 25*time.Second, statementTimeout(25*time.Second, 1))
+	assert.Equal(t, 5*time.Second, statementTimeout(5*time.Second, 0))
+}
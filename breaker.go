@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// breakerThreshold returns how many consecutive connection failures open the
+// circuit breaker, from MSSQL_BREAKER_THRESHOLD. Zero disables the breaker.
+func breakerThreshold() int {
+	return intEnv("MSSQL_BREAKER_THRESHOLD", defaultBreakerThreshold, 0)
+}
+
+// breakerCooldown returns how long an open breaker rejects calls before a
+// probe is allowed, from MSSQL_BREAKER_COOLDOWN_SECONDS.
+func breakerCooldown() time.Duration {
+	seconds := intEnv("MSSQL_BREAKER_COOLDOWN_SECONDS", 0, 1)
+	if seconds == 0 {
+		return defaultBreakerCooldown
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// breaker tracks consecutive connection failures for one pool. Callers must
+// hold the DatabaseManager write lock, which also ensures only one probe runs
+// once the cooldown expires.
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// check returns an error while the breaker is open.
+func (b *breaker) check() error {
+	if wait := time.Until(b.openUntil); wait > 0 {
+		return fmt.Errorf("database unavailable after %d consecutive connection failures, retry later (next attempt allowed in %v)", b.failures, wait.Round(time.Second))
+	}
+	return nil
+}
+
+// recordFailure counts a failed connection attempt and opens the breaker
+// once the threshold is reached. A failed probe re-opens it immediately.
+func (b *breaker) recordFailure() {
+	b.failures++
+	if threshold := breakerThreshold(); threshold > 0 && b.failures >= threshold {
+		b.openUntil = time.Now().Add(breakerCooldown())
+	}
+}
+
+// reset closes the breaker after a successful connection or a change of
+// connection string.
+func (b *breaker) reset() {
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Setenv("MSSQL_BREAKER_THRESHOLD", "2")
+	var b breaker
+
+	b.recordFailure()
+	assert.NoError(t, b.check(), "one failure is below the threshold")
+
+	b.recordFailure()
+	assert.ErrorContains(t, b.check(), "database unavailable after 2 consecutive connection failures")
+
+	// Once the cooldown has passed a probe is allowed; a failed probe
+	// re-opens the breaker straight away.
+	b.openUntil = time.Now().Add(-time.Second)
+	assert.NoError(t, b.check())
+	b.recordFailure()
+	assert.Error(t, b.check())
+
+	b.reset()
+	assert.NoError(t, b.check())
+}
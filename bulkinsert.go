@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// bulkInsertOptions make a bulk copy behave like the equivalent INSERTs:
+// check constraints are enforced, triggers fire, and an explicit NULL is
+// stored as NULL rather than replaced by the column default.
+var bulkInsertOptions = mssql.BulkOptions{
+	CheckConstraints: true,
+	FireTriggers:     true,
+	KeepNulls:        true,
+}
+
+// bulkInsertValues checks that every row has one value per column and
+// converts the decoded JSON values into driver arguments.
+func bulkInsertValues(columns []string, rows []interface{}) ([][]interface{}, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to insert")
+	}
+
+	values := make([][]interface{}, len(rows))
+	for r, raw := range rows {
+		row, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("row %d must be an array of values", r+1)
+		}
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row %d has %d value(s) but %d column(s) were given", r+1, len(row), len(columns))
+		}
+		values[r] = make([]interface{}, len(row))
+		for i, v := range row {
+			if _, ok := v.(map[string]interface{}); ok {
+				// Bulk copy converts each value to its column's type, so
+				// type hints are neither needed nor understood.
+				return nil, fmt.Errorf("row %d, column %s: type hints are not supported; values are converted to the column type", r+1, columns[i])
+			}
+			arg, err := coerceParam(v)
+			if err != nil {
+				return nil, fmt.Errorf("row %d, column %s: %v", r+1, columns[i], err)
+			}
+			values[r][i] = arg
+		}
+	}
+	return values, nil
+}
+
+// bulkInsert copies rows into table with the driver's bulk copy protocol, in
+// a single transaction so either every row is inserted or none is. The table
+// and columns are resolved against the catalog first. It reports the number
+// of rows inserted.
+func bulkInsert(ctx context.Context, dm *DatabaseManager, connection, table string, columns []string, rows []interface{}) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no columns given")
+	}
+	values, err := bulkInsertValues(columns, rows)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := resolveTable(ctx, dm, connection, table)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, len(columns))
+	seen := make(map[string]bool, len(columns))
+	for i, col := range columns {
+		name, ok := ref.column(col)
+		if !ok {
+			return "", fmt.Errorf("column '%s' not found in %s.%s (columns: %s)", col, ref.schema, ref.name, strings.Join(ref.columns, ", "))
+		}
+		if seen[name] {
+			return "", fmt.Errorf("column '%s' is listed more than once", name)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+
+	db, err := dm.getConnection(connection)
+	if err != nil {
+		return "", fmt.Errorf("database connection unavailable: %v", err)
+	}
+
+	timeout := queryTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", wrapQueryError(ctx, timeout, "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, mssql.CopyIn(tableName, bulkInsertOptions, names...))
+	if err != nil {
+		return "", wrapQueryError(ctx, timeout, "bulk insert failed", err)
+	}
+	defer stmt.Close()
+
+	for r, row := range values {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return "", wrapQueryError(ctx, timeout, fmt.Sprintf("bulk insert failed at row %d", r+1), err)
+		}
+	}
+	// An Exec without arguments flushes the buffered rows to the server.
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return "", wrapQueryError(ctx, timeout, "bulk insert failed", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("bulk insert failed: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit failed: %v", err)
+	}
+	return fmt.Sprintf("%d row(s) inserted into %s.%s.", inserted, ref.schema, ref.name), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkInsertValues(t *testing.T) {
+	columns := []string{"id", "name", "active"}
+	values, err := bulkInsertValues(columns, []interface{}{
+		[]interface{}{float64(1), "ada", true},
+		[]interface{}{float64(2), nil, false},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1), "ada", true}, {int64(2), nil, false}}, values)
+
+	_, err = bulkInsertValues(columns, nil)
+	assert.ErrorContains(t, err, "no rows to insert")
+
+	_, err = bulkInsertValues(columns, []interface{}{[]interface{}{float64(1), "ada"}})
+	assert.ErrorContains(t, err, "row 1 has 2 value(s) but 3 column(s) were given")
+
+	_, err = bulkInsertValues(columns, []interface{}{"not a row"})
+	assert.ErrorContains(t, err, "row 1 must be an array of values")
+
+	_, err = bulkInsertValues(columns, []interface{}{[]interface{}{float64(1), []interface{}{"nested"}, true}})
+	assert.ErrorContains(t, err, "row 1, column name: unsupported parameter type")
+
+	_, err = bulkInsertValues(columns, []interface{}{[]interface{}{float64(1), map[string]interface{}{"value": "x", "type": "nvarchar"}, true}})
+	assert.ErrorContains(t, err, "row 1, column name: type hints are not supported")
+}
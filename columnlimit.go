@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// limitColumns keeps the first n columns of r, dropping the rest from its
+// header, types and rows, and returns how many were dropped. An n of zero or
+// less keeps every column.
+func (r *queryResult) limitColumns(n int) int {
+	if n <= 0 || len(r.columns) <= n {
+		return 0
+	}
+	omitted := len(r.columns) - n
+	r.columns = r.columns[:n]
+	if len(r.types) > n {
+		r.types = r.types[:n]
+	}
+	for i, row := range r.rows {
+		r.rows[i] = row[:n]
+	}
+	return omitted
+}
+
+// columnLimitNote returns the trailer appended to output when max_columns
+// dropped omitted columns in total from the result sets.
+func columnLimitNote(limit, omitted int) string {
+	return fmt.Sprintf("\n... (%d column(s) beyond the first %d omitted; raise max_columns to see them)", omitted, limit)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitColumns(t *testing.T) {
+	newResult := func() *queryResult {
+		return &queryResult{
+			set:     1,
+			columns: []string{"id", "name", "email", "note"},
+			types:   []string{"INT", "NVARCHAR", "NVARCHAR", "NVARCHAR"},
+			rows:    [][]interface{}{{int64(1), "alice", "a@example.com", nil}},
+		}
+	}
+
+	result := newResult()
+	assert.Equal(t, 0, result.limitColumns(0))
+	assert.Equal(t, 0, result.limitColumns(4))
+	assert.Len(t, result.columns, 4)
+
+	assert.Equal(t, 2, result.limitColumns(2))
+	assert.Equal(t, []string{"id", "name"}, result.columns)
+	assert.Equal(t, []string{"INT", "NVARCHAR"}, result.types)
+	assert.Equal(t, [][]interface{}{{int64(1), "alice"}}, result.rows)
+
+	for format, want := range map[string]string{
+		formatJSON: `[{"id":1,"name":"alice"}]`,
+		formatCSV:  "id,name\n1,alice\n",
+	} {
+		result := newResult()
+		result.limitColumns(2)
+		out, err := formatResults([]*queryResult{result}, format, queryOptions{})
+		require.NoError(t, err, format)
+		assert.Equal(t, want, out, format)
+	}
+
+	assert.Equal(t, "\n... (2 column(s) beyond the first 2 omitted; raise max_columns to see them)", columnLimitNote(2, 2))
+}
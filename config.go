@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryTimeout   = 30 * time.Second
+	defaultMaxCallTimeout = 10 * time.Minute
+	defaultMaxRows        = 1000
+	defaultConnectRetries = 3
+	defaultPingTimeout    = 10 * time.Second
+	defaultAppName        = "go-mcp-server"
+	defaultMaxQueryLength = 100000
+
+	initialConnectBackoff = 500 * time.Millisecond
+	connectRetryWindow    = 30 * time.Second
+)
+
+// intEnv returns the integer value of the named environment variable, or
+// fallback when it is unset, unparseable, or below min.
+func intEnv(name string, fallback, min int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name)))
+	if err != nil || value < min {
+		return fallback
+	}
+	return value
+}
+
+// boolEnv reports whether the named environment variable is set to a true
+// value such as 1, true or yes.
+func boolEnv(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(name))) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// queryTimeout returns the timeout from MSSQL_QUERY_TIMEOUT_SECONDS, falling
+// back to the default when the variable is unset, unparseable, or not positive.
+func queryTimeout() time.Duration {
+	seconds := intEnv("MSSQL_QUERY_TIMEOUT_SECONDS", 0, 1)
+	if seconds == 0 {
+		return defaultQueryTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// maxCallTimeout returns the ceiling on the timeout_seconds a single call may
+// ask for, from MSSQL_MAX_QUERY_TIMEOUT_SECONDS. It is never below the global
+// query timeout.
+func maxCallTimeout() time.Duration {
+	ceiling := defaultMaxCallTimeout
+	if seconds := intEnv("MSSQL_MAX_QUERY_TIMEOUT_SECONDS", 0, 1); seconds > 0 {
+		ceiling = time.Duration(seconds) * time.Second
+	}
+	return max(ceiling, queryTimeout())
+}
+
+// maxRowsLimit returns the row cap from MSSQL_MAX_ROWS, falling back to the
+// default when the variable is unset, unparseable, or not positive.
+func maxRowsLimit() int {
+	return intEnv("MSSQL_MAX_ROWS", defaultMaxRows, 1)
+}
+
+// maxQueryLength returns the longest query text accepted, in characters, from
+// MSSQL_MAX_QUERY_LENGTH.
+func maxQueryLength() int {
+	return intEnv("MSSQL_MAX_QUERY_LENGTH", defaultMaxQueryLength, 1)
+}
+
+// maxCellWidth returns the character limit for a single cell in text output
+// from MSSQL_MAX_CELL_WIDTH, or zero when cells are shown in full.
+func maxCellWidth() int {
+	return intEnv("MSSQL_MAX_CELL_WIDTH", 0, 1)
+}
+
+// columnSeparator returns the gap between columns of text output from
+// MSSQL_COLUMN_SEPARATOR, or two spaces when it is unset or empty.
+func columnSeparator() string {
+	if separator := os.Getenv("MSSQL_COLUMN_SEPARATOR"); separator != "" {
+		return separator
+	}
+	return defaultColumnSeparator
+}
+
+// maxOutputBytes returns the size cap on a single tool result from
+// MSSQL_MAX_OUTPUT_BYTES, or zero when output is not capped.
+func maxOutputBytes() int {
+	return intEnv("MSSQL_MAX_OUTPUT_BYTES", 0, 1)
+}
+
+// connectRetries returns how many times a failed connection attempt is
+// retried, from MSSQL_CONNECT_RETRIES. Zero disables retries.
+func connectRetries() int {
+	return intEnv("MSSQL_CONNECT_RETRIES", defaultConnectRetries, 0)
+}
+
+// pingTimeout returns how long each connection attempt may take, from
+// MSSQL_CONNECT_TIMEOUT_SECONDS, falling back to the default when the variable
+// is unset, unparseable, or not positive.
+func pingTimeout() time.Duration {
+	seconds := intEnv("MSSQL_CONNECT_TIMEOUT_SECONDS", 0, 1)
+	if seconds == 0 {
+		return defaultPingTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// defaultSchema returns the schema from MSSQL_DEFAULT_SCHEMA that table tools
+// assume for unqualified names, or an empty string when none is configured.
+func defaultSchema() string {
+	return strings.Trim(strings.TrimSpace(os.Getenv("MSSQL_DEFAULT_SCHEMA")), "[]")
+}
+
+// appNameKeys are the connection string keys the driver reads the application
+// name from.
+var appNameKeys = map[string]bool{
+	"app name":         true,
+	"application name": true,
+}
+
+// withAppName adds the application name from MSSQL_APP_NAME to connString so
+// sessions from this server can be picked out in sys.dm_exec_sessions. A name
+// already present in the connection string is left alone.
+func withAppName(connString string) string {
+	name := strings.TrimSpace(os.Getenv("MSSQL_APP_NAME"))
+	if name == "" {
+		name = defaultAppName
+	}
+
+	if isURLConnString(connString) {
+		u, err := url.Parse(strings.TrimSpace(connString))
+		if err != nil {
+			return connString
+		}
+		for key := range u.Query() {
+			if appNameKeys[strings.ToLower(key)] {
+				return connString
+			}
+		}
+		separator := "?"
+		if strings.Contains(connString, "?") {
+			separator = "&"
+		}
+		return strings.TrimSpace(connString) + separator + url.QueryEscape("app name") + "=" + url.QueryEscape(name)
+	}
+
+	for _, part := range strings.Split(connString, ";") {
+		key, _, _ := strings.Cut(part, "=")
+		if appNameKeys[strings.ToLower(strings.TrimSpace(key))] {
+			return connString
+		}
+	}
+	connString = strings.TrimRight(strings.TrimSpace(connString), ";")
+	return connString + ";app name=" + strings.ReplaceAll(name, ";", "")
+}
+
+// discreteConnectionString assembles a URL connection string from MSSQL_HOST,
+// MSSQL_PORT, MSSQL_USER, MSSQL_PASSWORD, MSSQL_DATABASE and MSSQL_ENCRYPT, for
+// deployments that inject each setting as its own secret. It returns an empty
+// string when MSSQL_HOST is unset. A named instance may be given as
+// host\instance.
+func discreteConnectionString() string {
+	host := strings.TrimSpace(os.Getenv("MSSQL_HOST"))
+	if host == "" {
+		return ""
+	}
+
+	u := url.URL{Scheme: "sqlserver", Host: host}
+	if server, instance, ok := strings.Cut(host, `\`); ok {
+		u.Host = server
+		u.Path = "/" + instance
+	}
+	if port := strings.TrimSpace(os.Getenv("MSSQL_PORT")); port != "" {
+		u.Host += ":" + port
+	}
+	if user := os.Getenv("MSSQL_USER"); user != "" {
+		u.User = url.UserPassword(user, os.Getenv("MSSQL_PASSWORD"))
+	}
+
+	query := url.Values{}
+	if database := strings.TrimSpace(os.Getenv("MSSQL_DATABASE")); database != "" {
+		query.Set("database", database)
+	}
+	if encrypt := strings.TrimSpace(os.Getenv("MSSQL_ENCRYPT")); encrypt != "" {
+		query.Set("encrypt", encrypt)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// defaultConnectionString returns the default connection string. The file
+// named by MSSQL_CONNECTION_STRING_FILE wins, so a mounted secret can be
+// rotated in place; then MSSQL_CONNECTION_STRING; then the string built from
+// the discrete MSSQL_HOST family of variables. The file is read on every call.
+func defaultConnectionString() (string, error) {
+	if path := strings.TrimSpace(os.Getenv("MSSQL_CONNECTION_STRING_FILE")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read MSSQL_CONNECTION_STRING_FILE: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if connString := os.Getenv("MSSQL_CONNECTION_STRING"); connString != "" {
+		return expandEnvReferences(connString, "MSSQL_CONNECTION_STRING")
+	}
+	return discreteConnectionString(), nil
+}
+
+// expandEnvReferences replaces each ${VAR} in connString with the value of
+// the environment variable VAR, so credentials kept in separate variables can
+// be composed into one connection string. A reference to an unset variable is
+// an error rather than being passed to the driver literally; source names the
+// setting in the message. A bare $ is left alone.
+func expandEnvReferences(connString, source string) (string, error) {
+	var expanded strings.Builder
+	for {
+		start := strings.Index(connString, "${")
+		if start < 0 {
+			expanded.WriteString(connString)
+			return expanded.String(), nil
+		}
+		length := strings.IndexByte(connString[start+2:], '}')
+		if length < 0 {
+			return "", fmt.Errorf("%s has an unterminated ${ reference", source)
+		}
+		name := connString[start+2 : start+2+length]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("%s references ${%s}, which is not set", source, name)
+		}
+		expanded.WriteString(connString[:start])
+		expanded.WriteString(value)
+		connString = connString[start+2+length+1:]
+	}
+}
+
+// defaultConnectionName is accepted as an explicit alias for the connection
+// configured by MSSQL_CONNECTION_STRING or MSSQL_HOST.
+const defaultConnectionName = "default"
+
+func normalizeConnectionName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == defaultConnectionName {
+		return ""
+	}
+	return name
+}
+
+// connectionEnvName maps a connection name to its MSSQL_CONN_<NAME> variable.
+func connectionEnvName(name string) string {
+	return "MSSQL_CONN_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
+
+// namedConnections parses MSSQL_CONNECTIONS, a JSON object mapping connection
+// names to connection strings. Names are matched case-insensitively.
+func namedConnections() (map[string]string, error) {
+	raw := strings.TrimSpace(os.Getenv("MSSQL_CONNECTIONS"))
+	if raw == "" {
+		return nil, nil
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("MSSQL_CONNECTIONS must be a JSON object of name to connection string: %v", err)
+	}
+
+	conns := make(map[string]string, len(parsed))
+	for name, connString := range parsed {
+		conns[normalizeConnectionName(name)] = connString
+	}
+	return conns, nil
+}
+
+// connectionString resolves a normalized connection name to its connection
+// string. MSSQL_CONN_<NAME> takes precedence over MSSQL_CONNECTIONS, and
+// replicaConnectionName selects MSSQL_READONLY_CONNECTION_STRING.
+func connectionString(name string) (string, error) {
+	switch name {
+	case "":
+		return defaultConnectionString()
+	case replicaConnectionName:
+		return replicaConnectionString()
+	}
+
+	if connString := os.Getenv(connectionEnvName(name)); connString != "" {
+		return connString, nil
+	}
+
+	conns, err := namedConnections()
+	if err != nil {
+		return "", err
+	}
+	if connString, ok := conns[name]; ok && connString != "" {
+		return connString, nil
+	}
+
+	return "", fmt.Errorf("unknown connection '%s' (configured: %s)", name, strings.Join(connectionNames(), ", "))
+}
+
+// connectionNames lists every configured connection name, including the
+// default one when MSSQL_CONNECTION_STRING_FILE, MSSQL_CONNECTION_STRING or
+// MSSQL_HOST is set.
+func connectionNames() []string {
+	seen := make(map[string]bool)
+	if connString, err := defaultConnectionString(); err != nil || connString != "" {
+		seen[defaultConnectionName] = true
+	}
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if name, ok := strings.CutPrefix(key, "MSSQL_CONN_"); ok && value != "" {
+			seen[strings.ToLower(name)] = true
+		}
+	}
+	if conns, err := namedConnections(); err == nil {
+		for name := range conns {
+			if name != "" {
+				seen[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	return names
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionString(t *testing.T) {
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=primary")
+	t.Setenv("MSSQL_CONN_STAGING", "server=staging")
+	t.Setenv("MSSQL_CONNECTIONS", `{"Reporting": "server=reporting", "staging": "server=ignored"}`)
+
+	cases := map[string]string{
+		"":          "server=primary",
+		"default":   "server=primary",
+		"staging":   "server=staging",
+		"reporting": "server=reporting",
+	}
+	for name, want := range cases {
+		got, err := connectionString(normalizeConnectionName(name))
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	_, err := connectionString("missing")
+	assert.ErrorContains(t, err, "default, reporting, staging")
+}
+
+func TestDiscreteConnectionString(t *testing.T) {
+	t.Setenv("MSSQL_CONNECTION_STRING_FILE", "")
+	t.Setenv("MSSQL_CONNECTION_STRING", "")
+	t.Setenv("MSSQL_HOST", "")
+	connString, err := defaultConnectionString()
+	require.NoError(t, err)
+	assert.Equal(t, "", connString)
+
+	password := `p@ss:w/rd;?#&= %x"'`
+	t.Setenv("MSSQL_HOST", "db.internal")
+	t.Setenv("MSSQL_PORT", "1444")
+	t.Setenv("MSSQL_USER", "app_user")
+	t.Setenv("MSSQL_PASSWORD", password)
+	t.Setenv("MSSQL_DATABASE", "Sales")
+	t.Setenv("MSSQL_ENCRYPT", "true")
+
+	connString, err = defaultConnectionString()
+	require.NoError(t, err)
+	assert.NotContains(t, connString, password)
+	cfg, params, err := msdsn.Parse(connString)
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", cfg.Host)
+	assert.Equal(t, uint64(1444), cfg.Port)
+	assert.Equal(t, "app_user", cfg.User)
+	assert.Equal(t, password, cfg.Password)
+	assert.Equal(t, "Sales", cfg.Database)
+	assert.Equal(t, "true", params["encrypt"])
+	assert.Equal(t, []string{"default"}, connectionNames())
+
+	t.Setenv("MSSQL_HOST", `localhost\SQLEXPRESS`)
+	t.Setenv("MSSQL_PORT", "")
+	connString, err = defaultConnectionString()
+	require.NoError(t, err)
+	cfg, _, err = msdsn.Parse(connString)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", cfg.Host)
+	assert.Equal(t, "SQLEXPRESS", cfg.Instance)
+
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=primary")
+	connString, err = defaultConnectionString()
+	require.NoError(t, err)
+	assert.Equal(t, "server=primary", connString)
+}
+
+func TestConnectionStringFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conn")
+	require.NoError(t, os.WriteFile(path, []byte("server=from-file;password=a b\n"), 0o600))
+	t.Setenv("MSSQL_CONNECTION_STRING_FILE", path)
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=from-env")
+
+	connString, err := connectionString("")
+	require.NoError(t, err)
+	assert.Equal(t, "server=from-file;password=a b", connString)
+
+	// Rotating the secret takes effect on the next read.
+	require.NoError(t, os.WriteFile(path, []byte("server=rotated\r\n"), 0o600))
+	connString, err = connectionString("")
+	require.NoError(t, err)
+	assert.Equal(t, "server=rotated", connString)
+
+	require.NoError(t, os.Remove(path))
+	_, err = connectionString("")
+	assert.ErrorContains(t, err, "MSSQL_CONNECTION_STRING_FILE")
+}
+
+func TestConnectionStringEnvReferences(t *testing.T) {
+	t.Setenv("MSSQL_CONNECTION_STRING_FILE", "")
+	t.Setenv("DB_USER", "app_user")
+	t.Setenv("DB_PASSWORD", "p$ss;{x}")
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=db;user id=${DB_USER};password=${DB_PASSWORD};app name=a$b")
+
+	connString, err := connectionString("")
+	require.NoError(t, err)
+	assert.Equal(t, "server=db;user id=app_user;password=p$ss;{x};app name=a$b", connString)
+
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=db;password=${DB_MISSING}")
+	_, err = connectionString("")
+	assert.EqualError(t, err, "MSSQL_CONNECTION_STRING references ${DB_MISSING}, which is not set")
+
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=db;password=${DB_PASSWORD")
+	_, err = connectionString("")
+	assert.ErrorContains(t, err, "unterminated")
+}
+
+func TestPingTimeout(t *testing.T) {
+	t.Setenv("MSSQL_CONNECT_TIMEOUT_SECONDS", "")
+	assert.Equal(t, defaultPingTimeout, pingTimeout())
+
+	t.Setenv("MSSQL_CONNECT_TIMEOUT_SECONDS", "45")
+	assert.Equal(t, 45*time.Second, pingTimeout())
+
+	for _, bad := range []string{"0", "-5", "ten"} {
+		t.Setenv("MSSQL_CONNECT_TIMEOUT_SECONDS", bad)
+		assert.Equal(t, defaultPingTimeout, pingTimeout(), bad)
+	}
+}
+
+func TestWithAppName(t *testing.T) {
+	t.Setenv("MSSQL_APP_NAME", "")
+	cases := map[string]string{
+		"server=db;user id=sa;":                    "server=db;user id=sa;app name=go-mcp-server",
+		"server=db;App Name=reports":               "server=db;App Name=reports",
+		"server=db;application name=reports":       "server=db;application name=reports",
+		"sqlserver://sa:pw@db:1433":                "sqlserver://sa:pw@db:1433?app+name=go-mcp-server",
+		"sqlserver://sa:pw@db:1433?database=x":     "sqlserver://sa:pw@db:1433?database=x&app+name=go-mcp-server",
+		"sqlserver://sa:pw@db:1433?app+name=other": "sqlserver://sa:pw@db:1433?app+name=other",
+	}
+	for connString, want := range cases {
+		assert.Equal(t, want, withAppName(connString), connString)
+	}
+
+	t.Setenv("MSSQL_APP_NAME", "agent-gateway")
+	assert.Equal(t, "server=db;app name=agent-gateway", withAppName("server=db"))
+}
+
+func TestBoolEnv(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "true": true, " YES ": true, "1": true, "0": false, "off": false, "maybe": false} {
+		t.Setenv("MSSQL_VALIDATE_ON_START", value)
+		assert.Equal(t, want, boolEnv("MSSQL_VALIDATE_ON_START"), value)
+	}
+}
+
+func TestDefaultSchema(t *testing.T) {
+	t.Setenv("MSSQL_DEFAULT_SCHEMA", "")
+	assert.Equal(t, "", defaultSchema())
+
+	t.Setenv("MSSQL_DEFAULT_SCHEMA", " [sales] ")
+	assert.Equal(t, "sales", defaultSchema())
+}
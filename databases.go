@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// systemDatabaseMaxID is the highest database_id used by the built-in
+// databases (master, tempdb, model and msdb).
+const systemDatabaseMaxID = 4
+
+// listDatabases returns the catalogs on the instance behind connection. The
+// system databases are only included when includeSystem is set.
+func listDatabases(ctx context.Context, dm *DatabaseManager, connection string, includeSystem bool) (string, error) {
+	query := "SELECT name, database_id, create_date FROM sys.databases"
+	var args []interface{}
+	if !includeSystem {
+		query += "\nWHERE database_id > @p1"
+		args = append(args, systemDatabaseMaxID)
+	}
+	query += "\nORDER BY name"
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection}, query, args...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.rows) == 0 {
+		return "No databases found.", nil
+	}
+
+	return formatTable(result.columns, result.types, result.rows, defaultNullText) + result.truncationNote(), nil
+}
+
+// useDatabase reserves a dedicated connection from db and switches it to the
+// named database, which must exist in sys.databases. The returned release
+// function switches the connection back to its original database before
+// returning it to the pool, or discards it if that fails, so the USE never
+// leaks into other calls.
+func useDatabase(ctx context.Context, db *sql.DB, database string) (*sql.Conn, func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reserve a connection: %v", err)
+	}
+
+	var name, original string
+	err = conn.QueryRowContext(ctx, "SELECT name, DB_NAME() FROM sys.databases WHERE name = @p1", database).Scan(&name, &original)
+	if err != nil {
+		conn.Close()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("unknown database '%s'", database)
+		}
+		return nil, nil, fmt.Errorf("failed to look up database '%s': %v", database, err)
+	}
+
+	quotedName, err := quoteIdentifier(name)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	quotedOriginal, err := quoteIdentifier(original)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	release := func() {
+		resetCtx, cancel := context.WithTimeout(context.Background(), sessionResetTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(resetCtx, "USE "+quotedOriginal); err != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	if _, err := conn.ExecContext(ctx, "USE "+quotedName); err != nil {
+		release()
+		return nil, nil, fmt.Errorf("failed to switch to database '%s': %v", name, err)
+	}
+
+	return conn, release, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ddlColumn is one column of a table as get_table_ddl scripts it.
+type ddlColumn struct {
+	name      string
+	typeName  string
+	maxLength int64
+	precision int64
+	scale     int64
+	nullable  bool
+	// identity holds the seed and increment of an IDENTITY column.
+	identity []string
+	// computed is the definition of a computed column; its type, nullability
+	// and default are then implied.
+	computed  string
+	persisted bool
+	// defaultName is empty for a system-named default constraint, whose
+	// generated name would clash if the DDL were run next to the original.
+	defaultName       string
+	defaultDefinition string
+}
+
+// ddlPrimaryKey is a table's primary key constraint.
+type ddlPrimaryKey struct {
+	// name is empty for a system-named constraint.
+	name      string
+	clustered bool
+	columns   []string
+}
+
+// columnTypeDDL renders a column's type the way it is declared, e.g.
+// nvarchar(50), varbinary(max) or datetime2(3). maxLength is in bytes, as
+// sys.columns reports it, and -1 means max. Types columnTypeName does not
+// size itself, those with a fractional-seconds scale and float, are sized
+// here.
+func columnTypeDDL(typeName string, maxLength, precision, scale int64) string {
+	switch strings.ToLower(typeName) {
+	case "nchar", "nvarchar":
+		if maxLength > 0 {
+			maxLength /= 2
+		}
+	case "datetime2", "datetimeoffset", "time":
+		return fmt.Sprintf("%s(%d)", typeName, scale)
+	case "float":
+		if precision != 53 {
+			return fmt.Sprintf("%s(%d)", typeName, precision)
+		}
+	}
+	return columnTypeName(typeName, maxLength, precision, scale)
+}
+
+// tableDDL renders the CREATE TABLE statement for tableName, already quoted,
+// from its columns and optional primary key.
+func tableDDL(tableName string, columns []ddlColumn, primaryKey *ddlPrimaryKey) (string, error) {
+	var lines []string
+	for _, column := range columns {
+		name, err := quoteIdentifier(column.name)
+		if err != nil {
+			return "", err
+		}
+
+		line := name + " "
+		if column.computed != "" {
+			line += "AS " + column.computed
+			if column.persisted {
+				line += " PERSISTED"
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		line += columnTypeDDL(column.typeName, column.maxLength, column.precision, column.scale)
+		if column.identity != nil {
+			line += fmt.Sprintf(" IDENTITY(%s, %s)", column.identity[0], column.identity[1])
+		}
+		if column.nullable {
+			line += " NULL"
+		} else {
+			line += " NOT NULL"
+		}
+		if column.defaultDefinition != "" {
+			if column.defaultName != "" {
+				constraint, err := quoteIdentifier(column.defaultName)
+				if err != nil {
+					return "", err
+				}
+				line += " CONSTRAINT " + constraint
+			}
+			line += " DEFAULT " + column.defaultDefinition
+		}
+		lines = append(lines, line)
+	}
+
+	if primaryKey != nil {
+		line := ""
+		if primaryKey.name != "" {
+			constraint, err := quoteIdentifier(primaryKey.name)
+			if err != nil {
+				return "", err
+			}
+			line = "CONSTRAINT " + constraint + " "
+		}
+		kind := "NONCLUSTERED"
+		if primaryKey.clustered {
+			kind = "CLUSTERED"
+		}
+		line += "PRIMARY KEY " + kind + " (" + strings.Join(primaryKey.columns, ", ") + ")"
+		lines = append(lines, line)
+	}
+
+	return "CREATE TABLE " + tableName + " (\n    " + strings.Join(lines, ",\n    ") + "\n);", nil
+}
+
+// getTableDDL reconstructs the CREATE TABLE statement for table from the
+// catalog views: columns with their types, nullability, identity, computed
+// definitions and defaults, and the primary key. Other indexes, foreign keys,
+// check constraints and triggers are not scripted. The table is resolved
+// against the catalog first.
+func getTableDDL(ctx context.Context, dm *DatabaseManager, connection, table string) (string, error) {
+	ref, err := resolveTable(ctx, dm, connection, table)
+	if err != nil {
+		return "", err
+	}
+
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+
+	opts := queryOptions{connection: connection, maxRows: schemaMaxRows}
+	result, err := runQuery(ctx, dm, opts, `SELECT c.name, TYPE_NAME(c.user_type_id), c.max_length, c.precision, c.scale, c.is_nullable,
+       c.is_identity, CONVERT(nvarchar(40), ic.seed_value), CONVERT(nvarchar(40), ic.increment_value),
+       cc.definition, cc.is_persisted, dc.name, dc.is_system_named, dc.definition
+FROM sys.columns c
+LEFT JOIN sys.identity_columns ic ON ic.object_id = c.object_id AND ic.column_id = c.column_id
+LEFT JOIN sys.computed_columns cc ON cc.object_id = c.object_id AND cc.column_id = c.column_id
+LEFT JOIN sys.default_constraints dc ON dc.parent_object_id = c.object_id AND dc.parent_column_id = c.column_id
+WHERE c.object_id = OBJECT_ID(@p1) AND OBJECTPROPERTY(c.object_id, 'IsUserTable') = 1
+ORDER BY c.column_id`, tableName)
+	if err != nil {
+		return "", err
+	}
+	if len(result.rows) == 0 {
+		return "", fmt.Errorf("%s.%s is not a table; only tables can be scripted", ref.schema, ref.name)
+	}
+
+	columns := make([]ddlColumn, len(result.rows))
+	for i, row := range result.rows {
+		column := ddlColumn{
+			name:      formatValue(row[0]),
+			typeName:  formatValue(row[1]),
+			maxLength: int64Value(row[2]),
+			precision: int64Value(row[3]),
+			scale:     int64Value(row[4]),
+			nullable:  row[5] == true,
+		}
+		if row[6] == true {
+			column.identity = []string{formatValue(row[7]), formatValue(row[8])}
+		}
+		if row[9] != nil {
+			column.computed = formatValue(row[9])
+			column.persisted = row[10] == true
+		}
+		if row[13] != nil {
+			column.defaultDefinition = formatValue(row[13])
+			if row[12] != true {
+				column.defaultName = formatValue(row[11])
+			}
+		}
+		columns[i] = column
+	}
+
+	result, err = runQuery(ctx, dm, opts, `SELECT kc.name, kc.is_system_named, i.type_desc, c.name, ic.is_descending_key
+FROM sys.key_constraints kc
+JOIN sys.indexes i ON i.object_id = kc.parent_object_id AND i.index_id = kc.unique_index_id
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE kc.parent_object_id = OBJECT_ID(@p1) AND kc.type = 'PK' AND ic.key_ordinal > 0
+ORDER BY ic.key_ordinal`, tableName)
+	if err != nil {
+		return "", err
+	}
+
+	var primaryKey *ddlPrimaryKey
+	for _, row := range result.rows {
+		if primaryKey == nil {
+			primaryKey = &ddlPrimaryKey{clustered: formatValue(row[2]) == "CLUSTERED"}
+			if row[1] != true {
+				primaryKey.name = formatValue(row[0])
+			}
+		}
+		column, err := quoteIdentifier(formatValue(row[3]))
+		if err != nil {
+			return "", err
+		}
+		if row[4] == true {
+			column += " DESC"
+		} else {
+			column += " ASC"
+		}
+		primaryKey.columns = append(primaryKey.columns, column)
+	}
+
+	return tableDDL(tableName, columns, primaryKey)
+}
+
+// int64Value returns v when the driver scanned it as an integer, and zero
+// otherwise.
+func int64Value(v interface{}) int64 {
+	n, _ := v.(int64)
+	return n
+}
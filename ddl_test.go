@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnTypeDDL(t *testing.T) {
+	assert.Equal(t, "int", columnTypeDDL("int", 4, 10, 0))
+	assert.Equal(t, "nvarchar(50)", columnTypeDDL("nvarchar", 100, 0, 0))
+	assert.Equal(t, "nvarchar(max)", columnTypeDDL("nvarchar", -1, 0, 0))
+	assert.Equal(t, "varbinary(16)", columnTypeDDL("varbinary", 16, 0, 0))
+	assert.Equal(t, "decimal(10,2)", columnTypeDDL("decimal", 9, 10, 2))
+	assert.Equal(t, "datetime2(3)", columnTypeDDL("datetime2", 7, 23, 3))
+	assert.Equal(t, "float", columnTypeDDL("float", 8, 53, 0))
+	assert.Equal(t, "float(24)", columnTypeDDL("float", 4, 24, 0))
+	assert.Equal(t, "datetime", columnTypeDDL("datetime", 8, 23, 3))
+}
+
+func TestTableDDL(t *testing.T) {
+	columns := []ddlColumn{
+		{name: "id", typeName: "int", maxLength: 4, precision: 10, identity: []string{"1", "1"}},
+		{name: "name", typeName: "nvarchar", maxLength: 200, nullable: true},
+		{name: "status", typeName: "tinyint", maxLength: 1, precision: 3, defaultName: "DF_orders_status", defaultDefinition: "((0))"},
+		{name: "created", typeName: "datetime2", maxLength: 8, precision: 27, scale: 7, defaultDefinition: "(sysutcdatetime())"},
+		{name: "total", computed: "([qty]*[price])", persisted: true},
+	}
+	primaryKey := &ddlPrimaryKey{name: "PK_orders", clustered: true, columns: []string{"[id] ASC"}}
+
+	ddl, err := tableDDL("[dbo].[orders]", columns, primaryKey)
+	require.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE [dbo].[orders] (
+    [id] int IDENTITY(1, 1) NOT NULL,
+    [name] nvarchar(100) NULL,
+    [status] tinyint NOT NULL CONSTRAINT [DF_orders_status] DEFAULT ((0)),
+    [created] datetime2(7) NOT NULL DEFAULT (sysutcdatetime()),
+    [total] AS ([qty]*[price]) PERSISTED,
+    CONSTRAINT [PK_orders] PRIMARY KEY CLUSTERED ([id] ASC)
+);`, ddl)
+
+	ddl, err = tableDDL("[dbo].[log]", columns[1:2], &ddlPrimaryKey{columns: []string{"[name] DESC"}})
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE [dbo].[log] (\n    [name] nvarchar(100) NULL,\n    PRIMARY KEY NONCLUSTERED ([name] DESC)\n);", ddl)
+}
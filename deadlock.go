@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+const (
+	deadlockErrorNumber    = 1205
+	defaultDeadlockRetries = 2
+	deadlockRetryDelay     = 100 * time.Millisecond
+)
+
+// deadlockRetries returns how many times a query chosen as a deadlock victim
+// is retried, from MSSQL_DEADLOCK_RETRIES. Zero disables retries.
+func deadlockRetries() int {
+	return intEnv("MSSQL_DEADLOCK_RETRIES", defaultDeadlockRetries, 0)
+}
+
+// isDeadlock reports whether err is SQL Server error 1205, raised when the
+// query was chosen as a deadlock victim and rolled back.
+func isDeadlock(err error) bool {
+	sqlErr, ok := sqlServerError(err)
+	return ok && sqlErr.Number == deadlockErrorNumber
+}
+
+// writeKeywords mark a query as modifying data or schema. SELECT ... INTO is
+// included because it creates a table.
+var writeKeywords = map[string]bool{
+	"insert": true, "update": true, "delete": true, "merge": true, "into": true,
+	"exec": true, "execute": true, "create": true, "alter": true, "drop": true,
+	"truncate": true, "grant": true, "revoke": true, "deny": true,
+}
+
+// otherStatementKeywords start statements that have side effects without
+// writing rows, or that can follow a SELECT in a batch without a semicolon.
+// None of them can appear inside a SELECT.
+var otherStatementKeywords = map[string]bool{
+	"backup": true, "restore": true, "dbcc": true, "kill": true, "use": true,
+	"set": true, "waitfor": true, "declare": true, "begin": true, "commit": true,
+	"rollback": true, "save": true, "print": true, "raiserror": true, "throw": true,
+	"bulk": true, "reconfigure": true, "shutdown": true, "checkpoint": true,
+	"revert": true, "setuser": true, "if": true, "while": true, "return": true,
+	"goto": true, "enable": true, "disable": true, "open": true, "close": true,
+	"deallocate": true, "readtext": true, "writetext": true, "updatetext": true,
+}
+
+// isReadOnlyQuery reports whether query is a single SELECT, optionally behind
+// a WITH clause, that is safe to run again. A batch with a second statement
+// after a semicolon, or a keyword that can only start another statement, is
+// not, and neither is anything else, including stored procedure calls.
+// Quoted identifiers such as [delete] are names, not keywords.
+func isReadOnlyQuery(query string) bool {
+	tokens := scanSQLTokenSpans(query, true)
+	// A leading semicolon, as in ;WITH, only ends an earlier statement.
+	for len(tokens) > 0 && tokens[0].text == ";" {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	i := skipCTEs(tokens)
+	if i < 0 || i >= len(tokens) || tokens[i].text != "select" {
+		return false
+	}
+	for _, token := range tokens {
+		if token.text == ";" || writeKeywords[token.text] || otherStatementKeywords[token.text] {
+			return false
+		}
+	}
+	return true
+}
+
+// withDeadlockRetry wraps run so a deadlock is retried up to
+// MSSQL_DEADLOCK_RETRIES times after a short randomized delay. Only read-only
+// queries are retried unless optIn is set, since repeating a write that
+// partially succeeded elsewhere in the batch may not be safe.
+func withDeadlockRetry(query string, optIn bool, run func(ctx context.Context) (string, error)) func(ctx context.Context) (string, error) {
+	if !optIn && !isReadOnlyQuery(query) {
+		return run
+	}
+	return func(ctx context.Context) (string, error) {
+		retries := deadlockRetries()
+		for attempt := 1; ; attempt++ {
+			result, err := run(ctx)
+			if err == nil || !isDeadlock(err) || attempt > retries {
+				return result, err
+			}
+
+			delay := deadlockRetryDelay + rand.N(deadlockRetryDelay*time.Duration(attempt))
+			fmt.Fprintf(os.Stderr, "Query was chosen as a deadlock victim (attempt %d of %d); retrying in %v\n", attempt, retries+1, delay.Round(time.Millisecond))
+			select {
+			case <-ctx.Done():
+				return result, err
+			case <-time.After(delay):
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	assert.True(t, isReadOnlyQuery("SELECT * FROM dbo.orders WHERE note = 'insert'"))
+	assert.True(t, isReadOnlyQuery("WITH c AS (SELECT 1 AS n) SELECT n FROM c"))
+	assert.False(t, isReadOnlyQuery("SELECT * INTO dbo.copy FROM dbo.orders"))
+	assert.False(t, isReadOnlyQuery("UPDATE dbo.orders SET total = 0"))
+	assert.False(t, isReadOnlyQuery("WITH c AS (SELECT 1 AS n) DELETE FROM dbo.orders"))
+	assert.False(t, isReadOnlyQuery("EXEC dbo.refresh"))
+
+	assert.True(t, isReadOnlyQuery(";WITH c AS (SELECT 1 AS n) SELECT n FROM c;"))
+	assert.True(t, isReadOnlyQuery("SELECT [insert], [delete] FROM dbo.audit ORDER BY 1 OFFSET 0 ROWS FETCH NEXT 5 ROWS ONLY"))
+	assert.True(t, isReadOnlyQuery("SELECT id FROM a UNION ALL SELECT id FROM b"))
+}
+
+func TestIsReadOnlyQueryRejectsBatches(t *testing.T) {
+	for _, query := range []string{
+		"SELECT 1; BACKUP DATABASE x TO DISK='c:\\x.bak'",
+		"SELECT 1; SET IDENTITY_INSERT t ON",
+		"SELECT 1; WAITFOR DELAY '0:0:5'",
+		"SELECT 1; [DELETE] FROM t",
+		"SELECT 1; SELECT 2",
+		"SELECT 1; DBCC CHECKDB",
+		"SELECT 1; RESTORE DATABASE x FROM DISK='c:\\x.bak'",
+		"SELECT 1; KILL 53",
+		"SELECT 1; USE master",
+		"SELECT 1 BACKUP DATABASE x TO DISK='c:\\x.bak'",
+		"SELECT 1 DECLARE @x int",
+		"WITH c AS (SELECT 1 AS n) SELECT n FROM c; TRUNCATE TABLE t",
+	} {
+		assert.False(t, isReadOnlyQuery(query), query)
+	}
+}
+
+func TestWithDeadlockRetry(t *testing.T) {
+	t.Setenv("MSSQL_DEADLOCK_RETRIES", "2")
+	deadlock := fmt.Errorf("query execution failed: %w", mssql.Error{Number: deadlockErrorNumber})
+
+	calls := 0
+	run := func(ctx context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", deadlock
+		}
+		return "ok", nil
+	}
+	result, err := withDeadlockRetry("SELECT 1", false, run)(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	_, err = withDeadlockRetry("UPDATE t SET x = 1", false, run)(context.Background())
+	assert.True(t, isDeadlock(err), "writes are not retried without opting in")
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	_, err = withDeadlockRetry("UPDATE t SET x = 1", true, run)(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
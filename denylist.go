@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultDenylist blocks OS command execution, external data access and
+// server-level destructive commands. Each entry is a sequence of keywords
+// that must appear as consecutive tokens.
+var defaultDenylist = []string{
+	"xp_cmdshell",
+	"sp_configure",
+	"sp_oacreate",
+	"xp_regwrite",
+	"xp_regdeletekey",
+	"openrowset",
+	"opendatasource",
+	"shutdown",
+	"reconfigure",
+	"kill",
+	"drop database",
+	"drop login",
+	"alter server",
+	"alter login",
+	"restore database",
+}
+
+// denylist returns the blocked patterns, each split into lowercase tokens.
+// MSSQL_DENYLIST replaces the built-in set with a comma-separated list, and
+// "none" disables the check.
+func denylist() [][]string {
+	patterns := defaultDenylist
+	if raw, ok := os.LookupEnv("MSSQL_DENYLIST"); ok {
+		if strings.EqualFold(strings.TrimSpace(raw), "none") {
+			return nil
+		}
+		patterns = strings.Split(raw, ",")
+	}
+
+	var denied [][]string
+	for _, pattern := range patterns {
+		if tokens := strings.Fields(strings.ToLower(pattern)); len(tokens) > 0 {
+			denied = append(denied, tokens)
+		}
+	}
+	return denied
+}
+
+// isWordRune reports whether r can be part of an unquoted T-SQL identifier or
+// keyword.
+func isWordRune(r rune) bool {
+	return r == '_' || r == '@' || r == '#' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// sqlTokens splits query into lowercase keywords and unquoted identifiers.
+// Comments, string literals and quoted identifiers ([name] or "name") are
+// skipped, so a column called [shutdown] or a value like 'kill' is not
+// mistaken for a statement.
+func sqlTokens(query string) []string {
+	return scanSQLTokens(query, false)
+}
+
+// sqlTokensWithSymbols is sqlTokens but also keeps punctuation such as *, (
+// and , as single-character tokens, and quoted identifiers in lowercase
+// brackets, so "Order Details" and [order details] both become
+// "[order details]".
+func sqlTokensWithSymbols(query string) []string {
+	return scanSQLTokens(query, true)
+}
+
+func scanSQLTokens(query string, symbols bool) []string {
+	spans := scanSQLTokenSpans(query, symbols)
+	tokens := make([]string, len(spans))
+	for i, span := range spans {
+		tokens[i] = span.text
+	}
+	return tokens
+}
+
+// sqlTokenSpan is a token from scanSQLTokenSpans with the rune offsets of its
+// source text, so callers can edit the query around it.
+type sqlTokenSpan struct {
+	text       string
+	start, end int
+}
+
+func scanSQLTokenSpans(query string, symbols bool) []sqlTokenSpan {
+	var tokens []sqlTokenSpan
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i += 2
+		case r == '\'' || r == '"' || r == '[':
+			open := i
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			i++
+			start := i
+			for i < len(runes) {
+				if runes[i] == closing {
+					// A doubled closing character is an escaped one.
+					if i+1 < len(runes) && runes[i+1] == closing {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			if symbols && closing != '\'' {
+				end := min(i, len(runes))
+				name := strings.ReplaceAll(string(runes[start:end]), string(closing)+string(closing), string(closing))
+				tokens = append(tokens, sqlTokenSpan{"[" + strings.ToLower(name) + "]", open, min(i+1, len(runes))})
+			}
+			i++
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			word := strings.ToLower(string(runes[start:i]))
+			// N'...' is a Unicode string literal, not the identifier N.
+			if word == "n" && i < len(runes) && runes[i] == '\'' {
+				continue
+			}
+			tokens = append(tokens, sqlTokenSpan{word, start, i})
+		default:
+			if symbols && !unicode.IsSpace(r) {
+				tokens = append(tokens, sqlTokenSpan{string(r), i, i + 1})
+			}
+			i++
+		}
+	}
+	return tokens
+}
+
+// checkQuery runs the checks every query must pass before it is sent to the
+// server: the MSSQL_MAX_QUERY_LENGTH limit, the denylist and the
+// MSSQL_ALLOWED_TABLES allowlist.
+func checkQuery(query string) error {
+	if length, limit := utf8.RuneCountInString(query), maxQueryLength(); length > limit {
+		return fmt.Errorf("query rejected: it is %d characters long, which exceeds the %d character limit (MSSQL_MAX_QUERY_LENGTH)", length, limit)
+	}
+	if err := checkDenylist(query); err != nil {
+		return err
+	}
+	return checkAllowedTables(query)
+}
+
+// checkDenylist rejects query if it contains a blocked construct, naming the
+// construct in the error. Quoted identifiers are matched by their unquoted
+// text, since EXEC [xp_cmdshell] runs the same procedure as EXEC xp_cmdshell;
+// only string literals and comments are ignored.
+func checkDenylist(query string) error {
+	denied := denylist()
+	if len(denied) == 0 {
+		return nil
+	}
+
+	var tokens []string
+	for _, token := range sqlTokensWithSymbols(query) {
+		if isIdentifierToken(token) {
+			tokens = append(tokens, identifierText(token))
+		}
+	}
+	for i := range tokens {
+		for _, pattern := range denied {
+			if i+len(pattern) > len(tokens) {
+				continue
+			}
+			matched := true
+			for j, word := range pattern {
+				if tokens[i+j] != word {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return fmt.Errorf("statement rejected: '%s' is blocked by the server's denylist", strings.ToUpper(strings.Join(pattern, " ")))
+			}
+		}
+	}
+	return nil
+}
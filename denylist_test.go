@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDenylist(t *testing.T) {
+	blocked := map[string]string{
+		"EXEC xp_cmdshell 'dir'":                     "XP_CMDSHELL",
+		"exec master..xp_cmdshell 'whoami'":          "XP_CMDSHELL",
+		"SHUTDOWN WITH NOWAIT":                       "SHUTDOWN",
+		"drop   database\n  Sales":                   "DROP DATABASE",
+		"SELECT 1; /* cleanup */ DROP DATABASE x":    "DROP DATABASE",
+		"EXEC [xp_cmdshell] 'dir'":                   "XP_CMDSHELL",
+		"EXEC master..[xp_cmdshell] 'dir'":           "XP_CMDSHELL",
+		"EXEC [master].[sys].[XP_CMDSHELL] 'dir'":    "XP_CMDSHELL",
+		"EXEC \"xp_cmdshell\" 'dir'":                 "XP_CMDSHELL",
+		"[SHUTDOWN]":                                 "SHUTDOWN",
+		"SELECT [shutdown], \"kill\" FROM dbo.flags": "SHUTDOWN",
+		"[drop] [database] x":                        "DROP DATABASE",
+	}
+	for query, construct := range blocked {
+		assert.ErrorContains(t, checkDenylist(query), "'"+construct+"' is blocked", query)
+	}
+
+	allowed := []string{
+		"SELECT shutdown_time FROM dbo.events",
+		"SELECT [shutdown_time], \"kill count\" FROM dbo.flags",
+		"SELECT 'DROP DATABASE x' AS note, N'xp_cmdshell' AS other",
+		"-- shutdown later\nSELECT 1",
+		"DROP TABLE #scratch",
+	}
+	for _, query := range allowed {
+		assert.NoError(t, checkDenylist(query), query)
+	}
+}
+
+func TestDenylistOverride(t *testing.T) {
+	t.Setenv("MSSQL_DENYLIST", "truncate table, xp_cmdshell")
+	assert.ErrorContains(t, checkDenylist("TRUNCATE TABLE dbo.orders"), "'TRUNCATE TABLE' is blocked")
+	assert.NoError(t, checkDenylist("SHUTDOWN"))
+
+	t.Setenv("MSSQL_DENYLIST", "none")
+	assert.NoError(t, checkDenylist("EXEC xp_cmdshell 'dir'"))
+}
+
+func TestCheckQueryLength(t *testing.T) {
+	t.Setenv("MSSQL_MAX_QUERY_LENGTH", "10")
+	assert.NoError(t, checkQuery("SELECT 1"))
+	assert.NoError(t, checkQuery("SELECT 'é'"), "length is counted in characters, not bytes")
+	assert.ErrorContains(t, checkQuery("SELECT 12345"), "12 characters long, which exceeds the 10 character limit")
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// diffSide is one of the two queries diff_queries compares.
+type diffSide struct {
+	connection string
+	query      string
+}
+
+// diffColumn is the column diff output prepends to each row to say which
+// query returned it.
+const diffColumn = "only_in"
+
+// checkDiffColumns rejects two results whose columns differ in number or name
+// (compared case-insensitively), since rows could not be compared.
+func checkDiffColumns(first, second []string) error {
+	mismatch := len(first) != len(second)
+	for i := 0; !mismatch && i < len(first); i++ {
+		mismatch = !strings.EqualFold(first[i], second[i])
+	}
+	if mismatch {
+		return fmt.Errorf("column mismatch: the first query returns (%s) but the second returns (%s)", strings.Join(first, ", "), strings.Join(second, ", "))
+	}
+	return nil
+}
+
+// diffRowKey encodes a row so rows compare equal exactly when every value
+// does. JSON keeps NULL apart from an empty string.
+func diffRowKey(row []interface{}) (string, error) {
+	values := make([]interface{}, len(row))
+	for i, v := range row {
+		values[i] = jsonValue(v)
+	}
+	key, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare row: %v", err)
+	}
+	return string(key), nil
+}
+
+// diffRows returns the rows of first that second lacks and the rows of second
+// that first lacks, comparing whole rows as multisets: a row returned twice
+// by one query and once by the other appears once in the diff. Rows keep the
+// order their query returned them in.
+func diffRows(first, second [][]interface{}) (onlyFirst, onlySecond [][]interface{}, err error) {
+	counts := make(map[string]int)
+	firstKeys := make([]string, len(first))
+	for i, row := range first {
+		if firstKeys[i], err = diffRowKey(row); err != nil {
+			return nil, nil, err
+		}
+		counts[firstKeys[i]]++
+	}
+	for _, row := range second {
+		key, err := diffRowKey(row)
+		if err != nil {
+			return nil, nil, err
+		}
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		onlySecond = append(onlySecond, row)
+	}
+	// Whatever second did not match is left over in counts.
+	for i, row := range first {
+		if counts[firstKeys[i]] > 0 {
+			counts[firstKeys[i]]--
+			onlyFirst = append(onlyFirst, row)
+		}
+	}
+	return onlyFirst, onlySecond, nil
+}
+
+// diffQueries runs two queries, each on its own connection, and returns the
+// rows present in one result but not the other, marked "first" or "second"
+// in a leading only_in column. Each query reads at most opts.maxRows rows and
+// the diff shows at most that many; a note says when either limit cut the
+// comparison or the output short.
+func diffQueries(ctx context.Context, dm *DatabaseManager, opts queryOptions, first, second diffSide, format string) (string, error) {
+	if format != formatText && format != formatJSON && format != formatCSV {
+		return "", fmt.Errorf("unsupported format '%s': expected %s, %s or %s", format, formatText, formatJSON, formatCSV)
+	}
+
+	run := func(side diffSide) (*queryResult, error) {
+		sideOpts := opts
+		sideOpts.connection = side.connection
+		return runQuery(ctx, dm, sideOpts, side.query)
+	}
+	firstResult, err := run(first)
+	if err != nil {
+		return "", fmt.Errorf("first query: %v", err)
+	}
+	secondResult, err := run(second)
+	if err != nil {
+		return "", fmt.Errorf("second query: %v", err)
+	}
+	if err := checkDiffColumns(firstResult.columns, secondResult.columns); err != nil {
+		return "", err
+	}
+
+	onlyFirst, onlySecond, err := diffRows(firstResult.rows, secondResult.rows)
+	if err != nil {
+		return "", err
+	}
+
+	var note string
+	if firstResult.truncated || secondResult.truncated {
+		note = fmt.Sprintf("\n... (only the first %d rows of each query were compared; pass max_rows to compare more)", firstResult.maxRows)
+	}
+
+	if len(onlyFirst) == 0 && len(onlySecond) == 0 {
+		return fmt.Sprintf("The results are identical (%d row(s) each).", len(firstResult.rows)) + note, nil
+	}
+
+	diff := &queryResult{
+		set:     1,
+		columns: append([]string{diffColumn}, firstResult.columns...),
+		types:   append([]string{"VARCHAR"}, firstResult.types...),
+		maxRows: firstResult.maxRows,
+	}
+	for _, row := range onlyFirst {
+		diff.rows = append(diff.rows, append([]interface{}{"first"}, row...))
+	}
+	for _, row := range onlySecond {
+		diff.rows = append(diff.rows, append([]interface{}{"second"}, row...))
+	}
+	if len(diff.rows) > diff.maxRows {
+		diff.rows = diff.rows[:diff.maxRows]
+		diff.truncated = true
+	}
+	maskResults([]*queryResult{diff})
+
+	output, err := formatResult(diff, format, "", opts)
+	if err != nil {
+		return "", err
+	}
+	summary := fmt.Sprintf("%d row(s) only in the first result, %d only in the second.", len(onlyFirst), len(onlySecond))
+	if format == formatText {
+		output = summary + "\n\n" + output
+	} else {
+		// Keep the data parseable; the summary follows it like a note.
+		note = "\n" + summary + note
+	}
+	return output + diff.truncationNote() + note, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRows(t *testing.T) {
+	first := [][]interface{}{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+		{int64(2), "bob"},
+		{int64(3), nil},
+	}
+	second := [][]interface{}{
+		{int64(2), "bob"},
+		{int64(1), "alice"},
+		{int64(3), ""},
+		{int64(4), []byte("dan")},
+	}
+
+	onlyFirst, onlySecond, err := diffRows(first, second)
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(2), "bob"}, {int64(3), nil}}, onlyFirst)
+	assert.Equal(t, [][]interface{}{{int64(3), ""}, {int64(4), []byte("dan")}}, onlySecond)
+
+	onlyFirst, onlySecond, err = diffRows(first, first)
+	require.NoError(t, err)
+	assert.Empty(t, onlyFirst)
+	assert.Empty(t, onlySecond)
+}
+
+func TestCheckDiffColumns(t *testing.T) {
+	assert.NoError(t, checkDiffColumns([]string{"id", "Name"}, []string{"ID", "name"}))
+	assert.EqualError(t, checkDiffColumns([]string{"id", "name"}, []string{"id"}),
+		"column mismatch: the first query returns (id, name) but the second returns (id)")
+	assert.Error(t, checkDiffColumns([]string{"id", "name"}, []string{"id", "email"}))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Connection string keys the driver reads its TLS settings from.
+const (
+	encryptKey         = "encrypt"
+	trustServerCertKey = "trustservercertificate"
+)
+
+// withRequiredEncryption forces encrypt=true and trustservercertificate=false
+// into connString when MSSQL_REQUIRE_ENCRYPTION is set, so a connection can
+// never fall back to cleartext or accept an unverified certificate. A
+// connection string that explicitly sets encrypt to anything other than true
+// is rejected rather than silently overridden.
+func withRequiredEncryption(connString string) (string, error) {
+	if !boolEnv("MSSQL_REQUIRE_ENCRYPTION") {
+		return connString, nil
+	}
+
+	if isURLConnString(connString) {
+		u, err := url.Parse(strings.TrimSpace(connString))
+		if err != nil {
+			return "", fmt.Errorf("invalid connection string: %v", err)
+		}
+		query := u.Query()
+		for key, values := range query {
+			switch strings.ToLower(key) {
+			case encryptKey:
+				if err := checkEncryptValue(values[len(values)-1]); err != nil {
+					return "", err
+				}
+				query.Del(key)
+			case trustServerCertKey:
+				query.Del(key)
+			}
+		}
+		query.Set(encryptKey, "true")
+		query.Set(trustServerCertKey, "false")
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	var kept []string
+	for _, part := range strings.Split(connString, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case encryptKey:
+			if err := checkEncryptValue(value); err != nil {
+				return "", err
+			}
+			continue
+		case trustServerCertKey:
+			continue
+		case "":
+			continue
+		}
+		kept = append(kept, part)
+	}
+	kept = append(kept, encryptKey+"=true", trustServerCertKey+"=false")
+	return strings.Join(kept, ";"), nil
+}
+
+// checkEncryptValue rejects an explicit encrypt setting that would allow an
+// unencrypted connection.
+func checkEncryptValue(value string) error {
+	value = strings.TrimSpace(value)
+	if enabled, err := strconv.ParseBool(value); err == nil && enabled {
+		return nil
+	}
+	return fmt.Errorf("MSSQL_REQUIRE_ENCRYPTION is set but the connection string has encrypt=%s, which allows an unencrypted connection; remove it or set encrypt=true", value)
+}
+
+// checkEncryptionOnStart validates every configured connection against
+// MSSQL_REQUIRE_ENCRYPTION, so a connection string that disables encryption
+// stops the server at launch instead of failing on the first query.
+func checkEncryptionOnStart() error {
+	if !boolEnv("MSSQL_REQUIRE_ENCRYPTION") {
+		return nil
+	}
+	for _, name := range connectionNames() {
+		connString, err := connectionString(normalizeConnectionName(name))
+		if err != nil || connString == "" {
+			continue
+		}
+		if _, err := withRequiredEncryption(connString); err != nil {
+			return fmt.Errorf("connection '%s': %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequiredEncryption(t *testing.T) {
+	t.Setenv("MSSQL_REQUIRE_ENCRYPTION", "")
+	got, err := withRequiredEncryption("server=db;encrypt=false")
+	require.NoError(t, err)
+	assert.Equal(t, "server=db;encrypt=false", got)
+
+	t.Setenv("MSSQL_REQUIRE_ENCRYPTION", "true")
+	for _, connString := range []string{
+		"server=db;user id=sa;TrustServerCertificate=true;",
+		"server=db;Encrypt=True",
+		"sqlserver://sa:pw@db:1433?database=x&TrustServerCertificate=true",
+	} {
+		got, err := withRequiredEncryption(connString)
+		require.NoError(t, err, connString)
+		cfg, params, err := msdsn.Parse(got)
+		require.NoError(t, err, got)
+		assert.Equal(t, msdsn.Encryption(msdsn.EncryptionRequired), cfg.Encryption, got)
+		assert.Equal(t, "false", params["trustservercertificate"], got)
+	}
+
+	for _, connString := range []string{
+		"server=db;encrypt=false",
+		"server=db;Encrypt=disable",
+		"sqlserver://sa:pw@db:1433?encrypt=false",
+	} {
+		_, err := withRequiredEncryption(connString)
+		assert.ErrorContains(t, err, "allows an unencrypted connection", connString)
+	}
+}
+
+func TestCheckEncryptionOnStart(t *testing.T) {
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=primary")
+	t.Setenv("MSSQL_CONN_LEGACY", "server=legacy;encrypt=disable")
+
+	t.Setenv("MSSQL_REQUIRE_ENCRYPTION", "")
+	assert.NoError(t, checkEncryptionOnStart())
+
+	t.Setenv("MSSQL_REQUIRE_ENCRYPTION", "true")
+	assert.ErrorContains(t, checkEncryptionOnStart(), "connection 'legacy'")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPlan = `<?xml version="1.0" encoding="utf-16"?>
+<ShowPlanXML xmlns="http://schemas.microsoft.com/sqlserver/2004/07/showplan" Version="1.564">
+  <BatchSequence><Batch><Statements>
+    <StmtSimple StatementText="DECLARE @x int" StatementType="DECLARE" />
+    <StmtSimple StatementText="SELECT * FROM dbo.orders" StatementType="SELECT" StatementEstRows="48210.4">
+      <QueryPlan><RelOp EstimateRows="48210.4" /></QueryPlan>
+    </StmtSimple>
+    <StmtSimple StatementText="DELETE FROM dbo.log" StatementType="DELETE" StatementEstRows="3" />
+  </Statements></Batch></BatchSequence>
+</ShowPlanXML>`
+
+func TestPlanRowEstimates(t *testing.T) {
+	estimates, err := planRowEstimates(testPlan)
+	require.NoError(t, err)
+	assert.Equal(t, []rowEstimate{{"SELECT", 48210.4}, {"DELETE", 3}}, estimates)
+	assert.Equal(t, "Statement 1: Estimated rows: 48210 (SELECT)\nStatement 2: Estimated rows: 3 (DELETE)", formatRowEstimates(estimates))
+
+	assert.Equal(t, "Estimated rows: 3 (DELETE)", formatRowEstimates(estimates[1:]))
+	assert.Equal(t, "No row estimate is available for this query.", formatRowEstimates(nil))
+
+	_, err = planRowEstimates(`<ShowPlanXML><StmtSimple StatementEstRows="many" /></ShowPlanXML>`)
+	assert.ErrorContains(t, err, "StatementEstRows")
+}
+
+func TestEstimatedPlansRejectsShowplanOverride(t *testing.T) {
+	_, err := estimatedPlans(context.Background(), NewDatabaseManager(), "", "SET SHOWPLAN_XML OFF; DELETE FROM dbo.orders")
+	assert.ErrorContains(t, err, "may not change SET SHOWPLAN_XML")
+}
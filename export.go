@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// withinDir reports whether path is dir itself or lies beneath it. Both must
+// be clean absolute paths.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// exportPath resolves name to a file inside MSSQL_EXPORT_DIR, creating any
+// missing parent directories. Relative names are taken relative to the export
+// directory. Paths that escape it, whether through ".." or a symlink, are
+// rejected.
+func exportPath(name string) (string, error) {
+	dir := strings.TrimSpace(os.Getenv("MSSQL_EXPORT_DIR"))
+	if dir == "" {
+		return "", fmt.Errorf("exports are disabled: set MSSQL_EXPORT_DIR to the directory results may be written to")
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid MSSQL_EXPORT_DIR: %v", err)
+	}
+	root, err := filepath.EvalSymlinks(absDir)
+	if err != nil {
+		return "", fmt.Errorf("invalid MSSQL_EXPORT_DIR: %v", err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+	target := name
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+	if target == root || target == absDir || (!withinDir(root, target) && !withinDir(absDir, target)) {
+		return "", fmt.Errorf("path '%s' is outside MSSQL_EXPORT_DIR", name)
+	}
+
+	// Resolve the deepest directory that already exists before creating
+	// anything, so a symlink inside the export directory cannot get missing
+	// directories made elsewhere, let alone redirect the write.
+	parent := filepath.Dir(target)
+	existing := parent
+	for {
+		if _, err := os.Lstat(existing); err == nil || existing == filepath.Dir(existing) {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %v", err)
+	}
+	if !withinDir(root, resolved) {
+		return "", fmt.Errorf("path '%s' is outside MSSQL_EXPORT_DIR", name)
+	}
+	missing, err := filepath.Rel(existing, parent)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %v", err)
+	}
+	parent = filepath.Join(resolved, missing)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	parent, err = filepath.EvalSymlinks(parent)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %v", err)
+	}
+	if !withinDir(root, parent) {
+		return "", fmt.Errorf("path '%s' is outside MSSQL_EXPORT_DIR", name)
+	}
+	target = filepath.Join(parent, filepath.Base(target))
+
+	if info, err := os.Lstat(target); err == nil && !info.Mode().IsRegular() {
+		return "", fmt.Errorf("path '%s' exists and is not a regular file", name)
+	}
+	return target, nil
+}
+
+// utf8BOM is the byte-order mark Excel looks for to read a CSV file as UTF-8.
+const utf8BOM = "\ufeff"
+
+// csvFileOptions control the CSV files save_query_result writes. The zero
+// value gives plain RFC 4180 output: no byte-order mark and comma-separated.
+type csvFileOptions struct {
+	// bom starts the file with a UTF-8 byte-order mark.
+	bom bool
+	// delimiter separates fields; zero means a comma.
+	delimiter rune
+}
+
+// parseCSVDelimiter reads the delimiter argument of save_query_result: a
+// single character, or "tab". Empty means a comma.
+func parseCSVDelimiter(raw string) (rune, error) {
+	if raw == "" {
+		return ',', nil
+	}
+	if strings.EqualFold(raw, "tab") {
+		return '\t', nil
+	}
+	runes := []rune(raw)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' || runes[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q: expected a single character other than a quote or line break", raw)
+	}
+	return runes[0], nil
+}
+
+// newCSVFileWriter returns a CSV writer for out that honors opts, writing the
+// byte-order mark first when one is wanted.
+func newCSVFileWriter(out io.Writer, opts csvFileOptions) (*csv.Writer, error) {
+	if opts.bom {
+		if _, err := io.WriteString(out, utf8BOM); err != nil {
+			return nil, fmt.Errorf("failed to write export file: %v", err)
+		}
+	}
+	w := csv.NewWriter(out)
+	if opts.delimiter != 0 {
+		w.Comma = opts.delimiter
+	}
+	return w, nil
+}
+
+// exportFile is an export being written to a temporary file next to its
+// target, so a failed or cancelled export never leaves a partial file behind
+// or clobbers an earlier one. commit moves it into place.
+type exportFile struct {
+	*os.File
+	target string
+}
+
+// createExportFile starts an export to target.
+func createExportFile(target string) (*exportFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %v", err)
+	}
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create export file: %v", err)
+	}
+	return &exportFile{File: file, target: target}, nil
+}
+
+// commit closes the temporary file and renames it over the target.
+func (f *exportFile) commit() error {
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := os.Rename(f.Name(), f.target); err != nil {
+		return fmt.Errorf("failed to write export file: %v", err)
+	}
+	return nil
+}
+
+// discard closes and removes the temporary file. After commit it has
+// already been renamed away, so this does nothing.
+func (f *exportFile) discard() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// saveQueryResult runs query and writes the rows of its first result set to a
+// file inside MSSQL_EXPORT_DIR as CSV, a JSON array or Parquet, row by row as
+// they are read. csvOpts only apply to CSV files. It returns the file path and row
+// count rather than the data.
+func saveQueryResult(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args []interface{}, path, format string, csvOpts csvFileOptions) (string, error) {
+	if format != formatCSV && format != formatJSON && format != formatParquet {
+		return "", fmt.Errorf("unsupported format '%s': expected %s, %s or %s", format, formatCSV, formatJSON, formatParquet)
+	}
+	if format != formatCSV && (csvOpts.bom || csvOpts.delimiter != 0) {
+		return "", fmt.Errorf("bom and delimiter only apply to the %s format", formatCSV)
+	}
+
+	target, err := exportPath(path)
+	if err != nil {
+		return "", err
+	}
+	file, err := createExportFile(target)
+	if err != nil {
+		return "", err
+	}
+	defer file.discard()
+	out := bufio.NewWriter(file)
+
+	// Parquet columns are typed, so the writer maps the driver's values
+	// itself rather than taking the normalized ones.
+	opts.rawValues = format == formatParquet
+
+	csvWriter, err := newCSVFileWriter(out, csvOpts)
+	if err != nil {
+		return "", err
+	}
+	var keys [][]byte
+	var parquetWriter *parquetFileWriter
+	count := 0
+	masker := newColumnMasker()
+	results, err := scanRows(ctx, dm, opts, query, args, func(result *queryResult, values []interface{}) error {
+		if result.set > 1 {
+			return nil
+		}
+		masker.apply(result, values)
+		switch format {
+		case formatCSV:
+			if count == 0 {
+				if err := csvWriter.Write(result.columns); err != nil {
+					return fmt.Errorf("failed to write CSV header: %v", err)
+				}
+			}
+			record := make([]string, len(values))
+			for i, v := range values {
+				record[i] = formatValue(v)
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV row: %v", err)
+			}
+		case formatParquet:
+			if count == 0 {
+				parquetWriter = newParquetFileWriter(out, parquetColumnSpecs(result))
+			}
+			if err := parquetWriter.write(values); err != nil {
+				return err
+			}
+		default:
+			separator := ","
+			if count == 0 {
+				var err error
+				if keys, err = jsonKeys(result.columns); err != nil {
+					return err
+				}
+				separator = "["
+			}
+			object, err := jsonObject(result.columns, keys, values)
+			if err != nil {
+				return err
+			}
+			out.WriteString(separator)
+			out.Write(object)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// An empty result still produces a valid file.
+	switch {
+	case format == formatCSV && count == 0:
+		csvWriter.Write(results[0].columns)
+	case format == formatJSON && count == 0:
+		out.WriteString("[]")
+	case format == formatJSON:
+		out.WriteString("]")
+	case format == formatParquet && count == 0:
+		parquetWriter = newParquetFileWriter(out, parquetColumnSpecs(results[0]))
+	}
+	if parquetWriter != nil {
+		if err := parquetWriter.close(); err != nil {
+			return "", err
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return "", fmt.Errorf("failed to write CSV output: %v", err)
+	}
+	if err := out.Flush(); err != nil {
+		return "", fmt.Errorf("failed to write export file: %v", err)
+	}
+	if err := file.commit(); err != nil {
+		return "", err
+	}
+
+	note := ""
+	if results[0].truncated {
+		note = fmt.Sprintf(" (stopped at the %d row limit; pass max_rows to export more)", results[0].maxRows)
+	}
+	return fmt.Sprintf("Wrote %d row(s) to %s%s", count, target, note), nil
+}
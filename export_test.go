@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportPath(t *testing.T) {
+	t.Setenv("MSSQL_EXPORT_DIR", "")
+	_, err := exportPath("out.csv")
+	assert.ErrorContains(t, err, "exports are disabled")
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	t.Setenv("MSSQL_EXPORT_DIR", dir)
+
+	path, err := exportPath("reports/out.csv")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "reports", "out.csv"), path)
+	assert.DirExists(t, filepath.Join(dir, "reports"))
+
+	path, err = exportPath(filepath.Join(dir, "abs.json"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "abs.json"), path)
+
+	for _, escape := range []string{"../out.csv", "reports/../../out.csv", "/etc/passwd", "."} {
+		_, err := exportPath(escape)
+		assert.Error(t, err, escape)
+	}
+
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	_, err = exportPath("link/out.csv")
+	assert.ErrorContains(t, err, "outside MSSQL_EXPORT_DIR", "symlinks may not lead out of the export directory")
+
+	// Nothing is created outside the export directory on the way to the
+	// rejection.
+	_, err = exportPath("link/a/b/out.csv")
+	assert.ErrorContains(t, err, "outside MSSQL_EXPORT_DIR")
+	assert.NoDirExists(t, filepath.Join(outside, "a"))
+}
+
+func TestExportFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "out.csv")
+	require.NoError(t, os.WriteFile(target, []byte("old\n"), 0o644))
+
+	// A failed export leaves the earlier file as it was and no temp file.
+	file, err := createExportFile(target)
+	require.NoError(t, err)
+	_, err = file.WriteString("partial")
+	require.NoError(t, err)
+	file.discard()
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(content))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	file, err = createExportFile(target)
+	require.NoError(t, err)
+	_, err = file.WriteString("new\n")
+	require.NoError(t, err)
+	require.NoError(t, file.commit())
+	file.discard()
+	content, err = os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(content))
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestCSVFileWriter(t *testing.T) {
+	var out bytes.Buffer
+	w, err := newCSVFileWriter(&out, csvFileOptions{})
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]string{"id", "name"}))
+	w.Flush()
+	assert.Equal(t, "id,name\n", out.String())
+
+	out.Reset()
+	delimiter, err := parseCSVDelimiter(";")
+	require.NoError(t, err)
+	w, err = newCSVFileWriter(&out, csvFileOptions{bom: true, delimiter: delimiter})
+	require.NoError(t, err)
+	require.NoError(t, w.Write([]string{"id", "name"}))
+	require.NoError(t, w.Write([]string{"1", "Müller; Söhne"}))
+	w.Flush()
+	assert.Equal(t, []byte{0xEF, 0xBB, 0xBF}, out.Bytes()[:3])
+	assert.Equal(t, "id;name\n1;\"Müller; Söhne\"\n", out.String()[3:])
+}
+
+func TestParseCSVDelimiter(t *testing.T) {
+	for raw, want := range map[string]rune{"": ',', ";": ';', "|": '|', "tab": '\t', "TAB": '\t'} {
+		got, err := parseCSVDelimiter(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	for _, raw := range []string{";;", `"`, "\n", "\r"} {
+		_, err := parseCSVDelimiter(raw)
+		assert.Error(t, err, raw)
+	}
+}
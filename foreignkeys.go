@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// listForeignKeys lists foreign key constraints one column pair per row, as
+// parent table and column referencing table and column, so join paths can be
+// read straight off the output. With table set, only constraints where that
+// table is the parent or the referenced side are shown; it is resolved
+// against the catalog first.
+func listForeignKeys(ctx context.Context, dm *DatabaseManager, connection, table string) (string, error) {
+	query := `SELECT fk.name AS constraint_name,
+       OBJECT_SCHEMA_NAME(fk.parent_object_id) + '.' + OBJECT_NAME(fk.parent_object_id) AS parent_table,
+       pc.name AS parent_column,
+       OBJECT_SCHEMA_NAME(fk.referenced_object_id) + '.' + OBJECT_NAME(fk.referenced_object_id) AS referenced_table,
+       rc.name AS referenced_column
+FROM sys.foreign_keys fk
+JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id`
+	var args []interface{}
+	scope := "the database"
+	if table != "" {
+		ref, err := resolveTable(ctx, dm, connection, table)
+		if err != nil {
+			return "", err
+		}
+		tableName, err := ref.quotedName()
+		if err != nil {
+			return "", err
+		}
+		query += "\nWHERE fk.parent_object_id = OBJECT_ID(@p1) OR fk.referenced_object_id = OBJECT_ID(@p1)"
+		args = append(args, tableName)
+		scope = ref.schema + "." + ref.name
+	}
+	query += "\nORDER BY parent_table, constraint_name, fkc.constraint_column_id"
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection, maxRows: schemaMaxRows}, query, args...)
+	if err != nil {
+		return "", err
+	}
+	if len(result.rows) == 0 {
+		return fmt.Sprintf("No foreign keys found in %s.", scope), nil
+	}
+	return formatTable(result.columns, result.types, result.rows, defaultNullText) + result.truncationNote(), nil
+}
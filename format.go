@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats accepted by the execute_sql tool.
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+	formatNDJSON   = "ndjson"
+)
+
+func validateFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatCSV, formatMarkdown, formatNDJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported format '%s': expected one of %s, %s, %s, %s, %s", format, formatText, formatJSON, formatCSV, formatMarkdown, formatNDJSON)
+}
+
+// formatValue renders a single driver value for text output.
+func formatValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// defaultNullText is how NULL is shown in text output unless a call asks for
+// something else, so it stands apart from an empty string.
+const defaultNullText = "NULL"
+
+// formatCell renders a value for text output, showing NULL as nullText.
+func formatCell(v interface{}, nullText string) string {
+	if v == nil {
+		return nullText
+	}
+	return formatValue(v)
+}
+
+// cellEllipsis marks a cell value cut short by MSSQL_MAX_CELL_WIDTH.
+const cellEllipsis = "..."
+
+// truncateCell shortens s to its first width characters followed by an
+// ellipsis, reporting whether anything was cut. Multibyte runes are never
+// split. A width of zero or less leaves s untouched.
+func truncateCell(s string, width int) (string, bool) {
+	if width <= 0 || len(s) <= width {
+		return s, false
+	}
+	count := 0
+	for i := range s {
+		if count == width {
+			return s[:i] + cellEllipsis, true
+		}
+		count++
+	}
+	return s, false
+}
+
+// cellTruncationNote returns the trailer appended to text output when cells
+// were shortened to width characters.
+func cellTruncationNote(width int) string {
+	return fmt.Sprintf("\n... (values longer than %d characters were truncated)", width)
+}
+
+// defaultColumnSeparator is the gap between columns of text output unless
+// MSSQL_COLUMN_SEPARATOR or a call's separator asks for another.
+const defaultColumnSeparator = "  "
+
+// padCell pads val to width, on the left when rightAlign is set, and adds the
+// gap that follows the column.
+func padCell(val string, width int, rightAlign bool, gap string) string {
+	padding := strings.Repeat(" ", width-len(val))
+	if rightAlign {
+		return padding + val + gap
+	}
+	return val + padding + gap
+}
+
+// separatorRule is what stands under separator in the dashed line below the
+// header: a blank separator is kept, and any other has its spaces drawn as
+// dashes and its other characters as +, so " | " becomes "-+-".
+func separatorRule(separator string) string {
+	if strings.TrimSpace(separator) == "" {
+		return separator
+	}
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return '-'
+		}
+		return '+'
+	}, separator)
+}
+
+// formatTable renders rows as a fixed-width text table with a header and a
+// dashed separator line, with columns separated as MSSQL_COLUMN_SEPARATOR
+// sets.
+func formatTable(columns, types []string, rows [][]interface{}, nullText string) string {
+	return formatSeparatedTable(columns, types, rows, nullText, columnSeparator())
+}
+
+// formatSeparatedTable renders rows as a fixed-width text table with separator
+// between columns. NULLs are shown as nullText. Cells longer than
+// MSSQL_MAX_CELL_WIDTH are truncated before column widths are computed.
+// Columns whose entry in types is numeric are right-justified; types may be
+// shorter than columns or nil when the driver reported none. The last column
+// is followed by as many spaces as the separator is wide.
+func formatSeparatedTable(columns, types []string, rows [][]interface{}, nullText, separator string) string {
+	var output strings.Builder
+	maxWidth := maxCellWidth()
+	cellsTruncated := false
+
+	columnWidths := make([]int, len(columns))
+	for i, col := range columns {
+		columnWidths[i] = len(col)
+	}
+
+	textRows := make([][]string, len(rows))
+	for r, row := range rows {
+		rowValues := make([]string, len(columns))
+		for i := range columns {
+			val, cut := truncateCell(formatCell(row[i], nullText), maxWidth)
+			cellsTruncated = cellsTruncated || cut
+			rowValues[i] = val
+			if len(val) > columnWidths[i] {
+				columnWidths[i] = len(val)
+			}
+		}
+		textRows[r] = rowValues
+	}
+
+	rightAlign := make([]bool, len(columns))
+	for i := range columns {
+		rightAlign[i] = i < len(types) && isNumericType(types[i])
+	}
+
+	gaps := make([]string, len(columns))
+	for i := range gaps {
+		gaps[i] = separator
+	}
+	if len(gaps) > 0 {
+		gaps[len(gaps)-1] = strings.Repeat(" ", utf8.RuneCountInString(separator))
+	}
+
+	for i, col := range columns {
+		output.WriteString(padCell(col, columnWidths[i], rightAlign[i], gaps[i]))
+	}
+	output.WriteString("\n")
+
+	rule := separatorRule(separator)
+	for i, width := range columnWidths {
+		output.WriteString(strings.Repeat("-", width))
+		if i < len(columnWidths)-1 {
+			output.WriteString(rule)
+		}
+	}
+	output.WriteString("\n")
+
+	for _, row := range textRows {
+		for i, val := range row {
+			output.WriteString(padCell(val, columnWidths[i], rightAlign[i], gaps[i]))
+		}
+		output.WriteString("\n")
+	}
+
+	if cellsTruncated {
+		output.WriteString(cellTruncationNote(maxWidth))
+	}
+
+	return output.String()
+}
+
+// markdownEscaper keeps a cell inside its table column: pipes would start a
+// new cell and line breaks would end the row.
+var markdownEscaper = strings.NewReplacer("\\", "\\\\", "|", "\\|", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// formatMarkdownTable renders rows as a GitHub-flavored Markdown table with a
+// header row and a --- separator. NULLs are shown as nullText, and cells are
+// truncated to MSSQL_MAX_CELL_WIDTH like the text table.
+func formatMarkdownTable(columns []string, rows [][]interface{}, nullText string) string {
+	var output strings.Builder
+	maxWidth := maxCellWidth()
+	cellsTruncated := false
+
+	writeRow := func(cells []string) {
+		output.WriteString("|")
+		for _, cell := range cells {
+			output.WriteString(" ")
+			output.WriteString(markdownEscaper.Replace(cell))
+			output.WriteString(" |")
+		}
+		output.WriteString("\n")
+	}
+
+	writeRow(columns)
+	output.WriteString("|")
+	for range columns {
+		output.WriteString(" --- |")
+	}
+	output.WriteString("\n")
+
+	cells := make([]string, len(columns))
+	for _, row := range rows {
+		for i := range columns {
+			var cut bool
+			cells[i], cut = truncateCell(formatCell(row[i], nullText), maxWidth)
+			cellsTruncated = cellsTruncated || cut
+		}
+		writeRow(cells)
+	}
+
+	if cellsTruncated {
+		output.WriteString(cellTruncationNote(maxWidth))
+	}
+
+	return output.String()
+}
+
+// tsvEscaper keeps each field on a single line with no embedded delimiters.
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// writeTSVRow appends fields to output as one tab-delimited line. Backslashes,
+// tabs and line breaks inside fields are escaped.
+func writeTSVRow(output *strings.Builder, fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			output.WriteString("\t")
+		}
+		output.WriteString(tsvEscaper.Replace(field))
+	}
+	output.WriteString("\n")
+}
+
+// jsonValue converts a driver value into something encoding/json renders with
+// its natural JSON type. Byte slices are decoded as strings.
+func jsonValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// jsonColumnNames makes column names usable as object keys. Unnamed columns
+// become column_N after their 1-based position, and repeated names get a
+// numeric suffix (id, id_2, id_3, ...) so no value is overwritten.
+func jsonColumnNames(columns []string) []string {
+	names := make([]string, len(columns))
+	used := make(map[string]bool, len(columns))
+	for i, col := range columns {
+		if col == "" {
+			col = fmt.Sprintf("column_%d", i+1)
+		}
+		name := col
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", col, n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// jsonKeys encodes column names once for use with jsonObject, after making
+// them unique with jsonColumnNames.
+func jsonKeys(columns []string) ([][]byte, error) {
+	keys := make([][]byte, len(columns))
+	for i, col := range jsonColumnNames(columns) {
+		key, err := json.Marshal(col)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode column name %q: %v", col, err)
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// jsonObject renders one row as a JSON object. Keys are written in column
+// order rather than the sorted order encoding/json uses for maps.
+func jsonObject(columns []string, keys [][]byte, row []interface{}) ([]byte, error) {
+	object := []byte("{")
+	for i := range columns {
+		if i > 0 {
+			object = append(object, ',')
+		}
+		val, err := json.Marshal(jsonValue(row[i]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value for column %q: %v", columns[i], err)
+		}
+		object = append(object, keys[i]...)
+		object = append(object, ':')
+		object = append(object, val...)
+	}
+	return append(object, '}'), nil
+}
+
+// formatJSONRows renders rows as a JSON array of objects, keeping column
+// order.
+func formatJSONRows(columns []string, rows [][]interface{}) (string, error) {
+	keys, err := jsonKeys(columns)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	output.WriteString("[")
+	for r, row := range rows {
+		if r > 0 {
+			output.WriteString(",")
+		}
+		object, err := jsonObject(columns, keys, row)
+		if err != nil {
+			return "", err
+		}
+		output.Write(object)
+	}
+	output.WriteString("]")
+
+	return output.String(), nil
+}
+
+// formatNDJSONRows renders rows as newline-delimited JSON: one object per
+// line, keyed by column name in column order, with no enclosing array. No rows
+// give empty output.
+func formatNDJSONRows(columns []string, rows [][]interface{}) (string, error) {
+	keys, err := jsonKeys(columns)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	for _, row := range rows {
+		object, err := jsonObject(columns, keys, row)
+		if err != nil {
+			return "", err
+		}
+		output.Write(object)
+		output.WriteString("\n")
+	}
+
+	return output.String(), nil
+}
+
+// formatJSONTypes renders a JSON object mapping each column name to its SQL
+// Server type, in column order. Columns without a reported type are omitted.
+func formatJSONTypes(columns, types []string) (string, error) {
+	var output strings.Builder
+	output.WriteString("{")
+	written := 0
+	for i, col := range jsonColumnNames(columns) {
+		if i >= len(types) || types[i] == "" {
+			continue
+		}
+		key, err := json.Marshal(col)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode column name %q: %v", col, err)
+		}
+		val, err := json.Marshal(types[i])
+		if err != nil {
+			return "", fmt.Errorf("failed to encode type of column %q: %v", col, err)
+		}
+		if written > 0 {
+			output.WriteString(",")
+		}
+		output.Write(key)
+		output.WriteString(":")
+		output.Write(val)
+		written++
+	}
+	output.WriteString("}")
+
+	return output.String(), nil
+}
+
+// formatCSVRows renders rows as RFC 4180 CSV with a header row. NULLs are
+// written as empty fields.
+func formatCSVRows(columns []string, rows [][]interface{}) (string, error) {
+	var output strings.Builder
+	w := csv.NewWriter(&output)
+
+	if err := w.Write(columns); err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %v", err)
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i := range columns {
+			record[i] = formatValue(row[i])
+		}
+		if err := w.Write(record); err != nil {
+			return "", fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write CSV output: %v", err)
+	}
+
+	return output.String(), nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatJSONRows(t *testing.T) {
+	columns := []string{"id", "name", "active", "note"}
+	rows := [][]interface{}{
+		{int64(1), []byte("alice"), true, nil},
+		{int64(2), "bob", false, "has \"quotes\""},
+	}
+
+	out, err := formatJSONRows(columns, rows)
+	require.NoError(t, err)
+	assert.Equal(t,
+		`[{"id":1,"name":"alice","active":true,"note":null},{"id":2,"name":"bob","active":false,"note":"has \"quotes\""}]`,
+		out)
+
+	out, err = formatJSONRows(columns, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", out)
+}
+
+func TestFormatCSVRows(t *testing.T) {
+	columns := []string{"id", "comment"}
+	rows := [][]interface{}{
+		{int64(1), "plain"},
+		{int64(2), "a, b"},
+		{int64(3), "say \"hi\""},
+		{int64(4), "line1\nline2"},
+		{int64(5), nil},
+	}
+
+	out, err := formatCSVRows(columns, rows)
+	require.NoError(t, err)
+	assert.Equal(t, "id,comment\n1,plain\n2,\"a, b\"\n3,\"say \"\"hi\"\"\"\n4,\"line1\nline2\"\n5,\n", out)
+}
+
+func TestWriteTSVRow(t *testing.T) {
+	var out strings.Builder
+	writeTSVRow(&out, []string{"id", "name"})
+	writeTSVRow(&out, []string{"1", "tab\there"})
+	writeTSVRow(&out, []string{"2", "line1\nline2 C:\\temp"})
+	assert.Equal(t, "id\tname\n1\ttab\\there\n2\tline1\\nline2 C:\\\\temp\n", out.String())
+}
+
+func TestFormatJSONTypes(t *testing.T) {
+	out, err := formatJSONTypes([]string{"id", "computed", "name"}, []string{"INT", "", "NVARCHAR"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"INT","name":"NVARCHAR"}`, out)
+}
+
+func TestTypedColumns(t *testing.T) {
+	result := &queryResult{columns: []string{"id", "computed"}, types: []string{"INT", ""}}
+	assert.Equal(t, []string{"id (INT)", "computed"}, result.typedColumns())
+}
+
+func TestTruncateCell(t *testing.T) {
+	out, cut := truncateCell("hello world", 5)
+	assert.True(t, cut)
+	assert.Equal(t, "hello...", out)
+
+	out, cut = truncateCell("héllo wörld", 7)
+	assert.True(t, cut)
+	assert.Equal(t, "héllo w...", out, "multibyte runes count as one character")
+
+	out, cut = truncateCell("日本語テキスト", 3)
+	assert.True(t, cut)
+	assert.Equal(t, "日本語...", out)
+
+	out, cut = truncateCell("日本語", 3)
+	assert.False(t, cut, "three runes fit even though they take nine bytes")
+	assert.Equal(t, "日本語", out)
+
+	out, cut = truncateCell("unlimited", 0)
+	assert.False(t, cut)
+	assert.Equal(t, "unlimited", out)
+}
+
+func TestFormatTableMaxCellWidth(t *testing.T) {
+	t.Setenv("MSSQL_MAX_CELL_WIDTH", "4")
+	out := formatTable([]string{"id", "body"}, nil, [][]interface{}{{int64(1), "a long value"}}, defaultNullText)
+	assert.Equal(t, "id  body     \n--  -------\n1   a lo...  \n\n... (values longer than 4 characters were truncated)", out)
+}
+
+func TestFormatTableRightAlignsNumbers(t *testing.T) {
+	t.Setenv("MSSQL_MAX_CELL_WIDTH", "")
+	columns := []string{"id", "name", "amount"}
+	rows := [][]interface{}{{int64(7), "widget", "1234.50"}, {int64(1024), "x", nil}}
+
+	assert.Equal(t, "  id  name     amount  \n----  ------  -------\n   7  widget  1234.50  \n1024  x          NULL  \n",
+		formatTable(columns, []string{"INT", "NVARCHAR", "DECIMAL"}, rows, defaultNullText))
+	// Without reported types every column stays left-justified.
+	assert.Equal(t, "id    name    amount   \n----  ------  -------\n7     widget  1234.50  \n1024  x       NULL     \n",
+		formatTable(columns, nil, rows, defaultNullText))
+}
+
+func TestFormatTableColumnSeparator(t *testing.T) {
+	t.Setenv("MSSQL_MAX_CELL_WIDTH", "")
+	t.Setenv("MSSQL_COLUMN_SEPARATOR", " | ")
+	columns := []string{"id", "name", "amount"}
+	rows := [][]interface{}{{int64(7), "big widget", "1234.50"}, {int64(1024), "x", nil}}
+
+	out := formatTable(columns, []string{"INT", "NVARCHAR", "DECIMAL"}, rows, defaultNullText)
+	assert.Equal(t, "  id | name       |  amount   \n-----+------------+--------\n   7 | big widget | 1234.50   \n1024 | x          |    NULL   \n", out)
+
+	// Every separator, and the + under it, sits at the same position.
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	for _, col := range []int{5, 18} {
+		for _, line := range lines {
+			assert.Contains(t, "|+", string(line[col]), line)
+		}
+	}
+
+	// A call's separator wins over the variable.
+	assert.Equal(t, "id;note \n--+----\n1 ;NULL \n",
+		formatSeparatedTable([]string{"id", "note"}, nil, [][]interface{}{{int64(1), nil}}, defaultNullText, ";"))
+}
+
+func TestNullDistinctFromEmptyString(t *testing.T) {
+	columns := []string{"id", "note"}
+	rows := [][]interface{}{{int64(1), nil}, {int64(2), ""}}
+
+	assert.Equal(t, "id  note  \n--  ----\n1   NULL  \n2         \n", formatTable(columns, nil, rows, defaultNullText))
+	assert.Equal(t, "id  note   \n--  -----\n1   <nil>  \n2          \n", formatTable(columns, nil, rows, "<nil>"))
+
+	out, err := formatJSONRows(columns, rows)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1,"note":null},{"id":2,"note":""}]`, out)
+}
+
+func TestJSONColumnNames(t *testing.T) {
+	assert.Equal(t, []string{"column_1", "column_2"}, jsonColumnNames([]string{"", ""}))
+	assert.Equal(t, []string{"id", "name", "id_2", "name_2", "id_3"}, jsonColumnNames([]string{"id", "name", "id", "name", "id"}))
+	assert.Equal(t, []string{"id_2", "id", "id_3"}, jsonColumnNames([]string{"id_2", "id", "id"}), "generated names skip ones already taken")
+
+	out, err := formatJSONRows([]string{"id", "", "id"}, [][]interface{}{{int64(1), int64(2), int64(3)}})
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1,"column_2":2,"id_2":3}]`, out)
+}
+
+func TestFormatMarkdownTable(t *testing.T) {
+	t.Setenv("MSSQL_MAX_CELL_WIDTH", "")
+	columns := []string{"id", "a|b"}
+	rows := [][]interface{}{{int64(1), "x | y"}, {int64(2), nil}, {int64(3), "line\nbreak"}}
+
+	assert.Equal(t, "| id | a\\|b |\n"+
+		"| --- | --- |\n"+
+		"| 1 | x \\| y |\n"+
+		"| 2 | NULL |\n"+
+		"| 3 | line<br>break |\n", formatMarkdownTable(columns, rows, defaultNullText))
+	assert.Contains(t, formatMarkdownTable(columns, rows, ""), "| 2 |  |\n")
+	assert.NoError(t, validateFormat(formatMarkdown))
+}
+
+func TestFormatNDJSONRows(t *testing.T) {
+	columns := []string{"id", "name", "active", "note"}
+	rows := [][]interface{}{
+		{int64(1), []byte("alice"), true, nil},
+		{int64(2), "bob\nsmith", false, "has \"quotes\""},
+	}
+
+	out, err := formatNDJSONRows(columns, rows)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	for i, line := range lines {
+		var object map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &object), "line %d", i+1)
+		assert.Len(t, object, len(columns))
+	}
+	assert.Equal(t, `{"id":1,"name":"alice","active":true,"note":null}`, lines[0])
+	assert.Equal(t, `{"id":2,"name":"bob\nsmith","active":false,"note":"has \"quotes\""}`, lines[1])
+
+	out, err = formatNDJSONRows(columns, nil)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
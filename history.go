@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistorySize = 100
+	maxHistorySize     = 10000
+)
+
+// historySize returns how many queries query_history remembers, from
+// MSSQL_HISTORY_SIZE, capped at maxHistorySize. Zero disables the history.
+func historySize() int {
+	return min(intEnv("MSSQL_HISTORY_SIZE", defaultHistorySize, 0), maxHistorySize)
+}
+
+// historyEntry records one query run by a tool call.
+type historyEntry struct {
+	started    time.Time
+	duration   time.Duration
+	connection string
+	query      string
+	rows       int
+	// err is the failure message, or empty when the query succeeded.
+	err string
+}
+
+// queryHistory is a fixed-size ring buffer of the most recent queries. It is
+// safe for concurrent use, and a nil history records nothing.
+type queryHistory struct {
+	mu      sync.Mutex
+	entries []historyEntry
+	// next is the slot the following entry is written to.
+	next int
+	full bool
+}
+
+func newQueryHistory(size int) *queryHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &queryHistory{entries: make([]historyEntry, size)}
+}
+
+// record adds the outcome of query, started at started, to the history.
+func (h *queryHistory) record(connection, query string, started time.Time, rows int, err error) {
+	if h == nil {
+		return
+	}
+
+	entry := historyEntry{
+		started:    started,
+		duration:   time.Since(started),
+		connection: connection,
+		query:      query,
+		rows:       rows,
+	}
+	if err != nil {
+		entry.err = err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// recent returns up to limit entries, newest first. A limit of zero or less
+// returns everything held.
+func (h *queryHistory) recent(limit int) []historyEntry {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	count := h.next
+	if h.full {
+		count = len(h.entries)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+
+	entries := make([]historyEntry, limit)
+	for i := range entries {
+		entries[i] = h.entries[(h.next-1-i+len(h.entries))%len(h.entries)]
+	}
+	return entries
+}
+
+// list returns up to limit entries, newest first, for query_history. With
+// distinct set, repeated runs of a query are dropped before the limit is
+// applied, so limit counts distinct queries.
+func (h *queryHistory) list(limit int, distinct bool) []historyEntry {
+	if !distinct {
+		return h.recent(limit)
+	}
+	entries := distinctHistory(h.recent(0))
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// distinctHistory keeps only the first, and so newest, of entries that ran
+// the same query on the same connection, comparing queries by queryKey.
+func distinctHistory(entries []historyEntry) []historyEntry {
+	seen := make(map[string]bool)
+	var distinct []historyEntry
+	for _, e := range entries {
+		key := queryKey(e.connection, e.query)
+		if !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, e)
+		}
+	}
+	return distinct
+}
+
+// formatHistory renders history entries as a text table, or as a JSON array
+// of objects with format json. Query text is put on one line in the table.
+func formatHistory(entries []historyEntry, format string) (string, error) {
+	if format == formatJSON {
+		type jsonEntry struct {
+			StartedAt  string `json:"started_at"`
+			DurationMS int64  `json:"duration_ms"`
+			Connection string `json:"connection"`
+			Rows       int    `json:"rows"`
+			Success    bool   `json:"success"`
+			Error      string `json:"error,omitempty"`
+			Query      string `json:"query"`
+		}
+		out := make([]jsonEntry, len(entries))
+		for i, e := range entries {
+			out[i] = jsonEntry{
+				StartedAt:  e.started.Format(time.RFC3339Nano),
+				DurationMS: e.duration.Milliseconds(),
+				Connection: historyConnection(e.connection),
+				Rows:       e.rows,
+				Success:    e.err == "",
+				Error:      e.err,
+				Query:      e.query,
+			}
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode query history: %v", err)
+		}
+		return string(data), nil
+	}
+	if format != formatText {
+		return "", fmt.Errorf("unsupported format '%s': expected %s or %s", format, formatText, formatJSON)
+	}
+
+	if len(entries) == 0 {
+		return "No queries recorded yet.", nil
+	}
+	columns := []string{"started_at", "duration_ms", "connection", "rows", "status", "query"}
+	types := []string{"", "BIGINT", "", "INT"}
+	rows := make([][]interface{}, len(entries))
+	for i, e := range entries {
+		status := "ok"
+		if e.err != "" {
+			status = "error: " + strings.Join(strings.Fields(e.err), " ")
+		}
+		rows[i] = []interface{}{
+			e.started.Format("2006-01-02 15:04:05.000"),
+			e.duration.Milliseconds(),
+			historyConnection(e.connection),
+			e.rows,
+			status,
+			strings.ReplaceAll(normalizeQuery(e.query), "\n", " "),
+		}
+	}
+	return formatTable(columns, types, rows, defaultNullText), nil
+}
+
+// historyConnection names the default connection explicitly in the history.
+func historyConnection(connection string) string {
+	if normalizeConnectionName(connection) == "" {
+		return defaultConnectionName
+	}
+	return connection
+}
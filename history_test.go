@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHistoryRing(t *testing.T) {
+	h := newQueryHistory(3)
+	assert.Empty(t, h.recent(0))
+
+	for i := 1; i <= 5; i++ {
+		h.record("", fmt.Sprintf("SELECT %d", i), time.Now(), i, nil)
+	}
+	entries := h.recent(0)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "SELECT 5", entries[0].query)
+	assert.Equal(t, "SELECT 3", entries[2].query)
+	assert.Equal(t, 4, entries[1].rows)
+
+	assert.Len(t, h.recent(2), 2)
+
+	var disabled *queryHistory
+	disabled.record("", "SELECT 1", time.Now(), 1, nil)
+	assert.Nil(t, disabled.recent(0))
+	assert.Nil(t, newQueryHistory(0))
+}
+
+func TestQueryHistoryConcurrent(t *testing.T) {
+	h := newQueryHistory(50)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.record("", "SELECT 1", time.Now(), 1, nil)
+				h.recent(5)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, h.recent(0), 50)
+}
+
+func TestHistorySize(t *testing.T) {
+	t.Setenv("MSSQL_HISTORY_SIZE", "")
+	assert.Equal(t, defaultHistorySize, historySize())
+	t.Setenv("MSSQL_HISTORY_SIZE", "0")
+	assert.Equal(t, 0, historySize())
+	t.Setenv("MSSQL_HISTORY_SIZE", "999999")
+	assert.Equal(t, maxHistorySize, historySize())
+}
+
+func TestFormatHistory(t *testing.T) {
+	started := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	entries := []historyEntry{
+		{started: started, duration: 12 * time.Millisecond, connection: "staging", query: "SELECT *\nFROM t", rows: 3},
+		{started: started, duration: 5 * time.Millisecond, query: "SELECT x", err: "invalid column name 'x'"},
+	}
+
+	out, err := formatHistory(entries, formatText)
+	require.NoError(t, err)
+	assert.Contains(t, out, "2024-05-06 07:08:09.000           12  staging        3  ok")
+	assert.Contains(t, out, "SELECT * FROM t")
+	assert.Contains(t, out, "default        0  error: invalid column name 'x'")
+
+	out, err = formatHistory(entries[1:], formatJSON)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"started_at":"2024-05-06T07:08:09Z","duration_ms":5,"connection":"default","rows":0,"success":false,"error":"invalid column name 'x'","query":"SELECT x"}]`, out)
+
+	_, err = formatHistory(nil, formatCSV)
+	assert.ErrorContains(t, err, "unsupported format 'csv'")
+}
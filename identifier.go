@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxIdentifierLength is the longest name SQL Server accepts for an object,
+// the length of the sysname type.
+const maxIdentifierLength = 128
+
+// quoteIdentifier validates name against SQL Server's rules for delimited
+// identifiers and returns it wrapped in brackets, with any embedded ] doubled,
+// ready to be interpolated into SQL text. Every object name placed into a
+// query string must go through this function; values belong in parameters.
+// A qualified name typed by a user is split with splitTableName first, which
+// unquotes each part, so every part is quoted exactly once.
+func quoteIdentifier(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("identifier is empty")
+	}
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("identifier %q is not valid UTF-8", name)
+	}
+	if n := utf8.RuneCountInString(name); n > maxIdentifierLength {
+		return "", fmt.Errorf("identifier is %d characters long; SQL Server allows at most %d", n, maxIdentifierLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("identifier %q contains a control character", name)
+		}
+	}
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]", nil
+}
+
+// unquoteIdentifier strips the brackets or double quotes around one part of
+// an object name and undoes the doubling of the closing character inside.
+// Names that are not quoted are returned without surrounding spaces.
+func unquoteIdentifier(part string) string {
+	part = strings.TrimSpace(part)
+	if len(part) >= 2 {
+		switch {
+		case part[0] == '[' && part[len(part)-1] == ']':
+			return strings.ReplaceAll(part[1:len(part)-1], "]]", "]")
+		case part[0] == '"' && part[len(part)-1] == '"':
+			return strings.ReplaceAll(part[1:len(part)-1], `""`, `"`)
+		}
+	}
+	return part
+}
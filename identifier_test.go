@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"users":                  "[users]",
+		"odd]name":               "[odd]]name]",
+		"order details":          "[order details]",
+		"foo]; DROP TABLE x;--":  "[foo]]; DROP TABLE x;--]",
+		"[already]":              "[[already]]]",
+		"]]":                     "[]]]]]",
+		"Ünïcødé":                "[Ünïcødé]",
+		strings.Repeat("a", 128): "[" + strings.Repeat("a", 128) + "]",
+	}
+	for name, want := range cases {
+		got, err := quoteIdentifier(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+
+	for _, name := range []string{"", strings.Repeat("a", 129), "bad\x00name", "line\nbreak", "bad\xffutf8"} {
+		_, err := quoteIdentifier(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestQuoteIdentifierRoundTrip(t *testing.T) {
+	for _, name := range []string{"users", "odd]name", "a.b", "[already]", "]]", `say "hi"`} {
+		quoted, err := quoteIdentifier(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, name, unquoteIdentifier(quoted), name)
+	}
+
+	// User input is split before the parts are quoted, so dots inside
+	// brackets stay part of the name and nothing is quoted twice.
+	cases := map[string]string{
+		"[my.schema].[t]":   "[my.schema].[t]",
+		"dbo.[a.b]":         "[dbo].[a.b]",
+		"sales.[odd]]name]": "[sales].[odd]]name]",
+		`"x.y".Users`:       "[x.y].[Users]",
+	}
+	for input, want := range cases {
+		schema, name := splitTableName(input)
+		quoted, err := (&tableRef{schema: schema, name: name}).quotedName()
+		require.NoError(t, err, input)
+		assert.Equal(t, want, quoted, input)
+	}
+}
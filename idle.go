@@ -0,0 +1,67 @@
+package main
+
+import (
+	"time"
+)
+
+// idleCloseAfter returns how long a pool may go unused before it is closed,
+// from MSSQL_IDLE_CLOSE_SECONDS, or zero when idle pools are kept open.
+func idleCloseAfter() time.Duration {
+	return time.Duration(intEnv("MSSQL_IDLE_CLOSE_SECONDS", 0, 1)) * time.Second
+}
+
+// startIdleReaper closes pools that have been idle for MSSQL_IDLE_CLOSE_SECONDS
+// so the next call reconnects instead of tripping over connections the server
+// has dropped in the meantime, and drops async query results left uncollected
+// for MSSQL_ASYNC_RESULT_TTL_SECONDS. It does nothing when both are disabled.
+func (dm *DatabaseManager) startIdleReaper() {
+	idle := idleCloseAfter()
+	ttl := asyncResultTTL()
+	if idle == 0 && ttl == 0 {
+		return
+	}
+
+	interval := min(idle, ttl)
+	if interval == 0 {
+		interval = max(idle, ttl)
+	}
+	interval /= 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-dm.shutdownCtx.Done():
+				return
+			case <-ticker.C:
+				if idle > 0 {
+					dm.closeIdlePools(idle)
+				}
+				if ttl > 0 {
+					dm.evictAsyncResults(ttl)
+				}
+			}
+		}
+	}()
+}
+
+// closeIdlePools closes every pool that has not been handed out by
+// getConnection for at least idle and has no connection in use, so a running
+// query, open transaction or reserved connection is never cut off. The pool
+// keeps its connection string and breaker state, and getConnection reopens it
+// on the next call.
+func (dm *DatabaseManager) closeIdlePools(idle time.Duration) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for _, p := range dm.pools {
+		if p.db == nil || time.Since(time.Unix(0, p.lastUsed.Load())) < idle || p.db.Stats().InUse > 0 {
+			continue
+		}
+		p.db.Close()
+		p.db = nil
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// tableIndex is one index with its key and included columns in order.
+type tableIndex struct {
+	name       string
+	indexType  string
+	unique     bool
+	primaryKey bool
+	keys       []string
+	included   []string
+}
+
+// listIndexes describes the indexes on table as a text table: name, type
+// (CLUSTERED, NONCLUSTERED, ...), uniqueness, key columns in key order with
+// DESC marked, and included columns. The table is resolved against the
+// catalog first. Heaps have no index row of their own and are not listed.
+func listIndexes(ctx context.Context, dm *DatabaseManager, connection, table string) (string, error) {
+	ref, err := resolveTable(ctx, dm, connection, table)
+	if err != nil {
+		return "", err
+	}
+
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection, maxRows: schemaMaxRows}, `SELECT i.name, i.type_desc, i.is_unique, i.is_primary_key,
+       c.name, ic.is_included_column, ic.is_descending_key
+FROM sys.indexes i
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE i.object_id = OBJECT_ID(@p1) AND i.type > 0
+ORDER BY i.index_id, ic.is_included_column, ic.key_ordinal, ic.index_column_id`, tableName)
+	if err != nil {
+		return "", err
+	}
+
+	var indexes []*tableIndex
+	for _, row := range result.rows {
+		name := formatValue(row[0])
+		if len(indexes) == 0 || indexes[len(indexes)-1].name != name {
+			indexes = append(indexes, &tableIndex{
+				name:       name,
+				indexType:  formatValue(row[1]),
+				unique:     row[2] == true,
+				primaryKey: row[3] == true,
+			})
+		}
+		index := indexes[len(indexes)-1]
+		column := formatValue(row[4])
+		switch {
+		case row[5] == true:
+			index.included = append(index.included, column)
+		case row[6] == true:
+			index.keys = append(index.keys, column+" DESC")
+		default:
+			index.keys = append(index.keys, column)
+		}
+	}
+
+	if len(indexes) == 0 {
+		return fmt.Sprintf("No indexes on %s.%s.", ref.schema, ref.name), nil
+	}
+
+	rows := make([][]interface{}, len(indexes))
+	for i, index := range indexes {
+		rows[i] = []interface{}{
+			index.name,
+			index.indexType,
+			index.unique,
+			index.primaryKey,
+			strings.Join(index.keys, ", "),
+			strings.Join(index.included, ", "),
+		}
+	}
+	columns := []string{"index_name", "type", "is_unique", "is_primary_key", "key_columns", "included_columns"}
+	return formatTable(columns, nil, rows, defaultNullText) + result.truncationNote(), nil
+}
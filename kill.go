@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// killAllowed reports whether MSSQL_ALLOW_KILL lets kill_session end other
+// sessions. It is off by default since KILL rolls back the session's work.
+func killAllowed() bool {
+	return boolEnv("MSSQL_ALLOW_KILL")
+}
+
+// parseSessionID accepts a session ID given as a JSON number or a string of
+// digits. KILL cannot take a parameter, so the ID is spliced into the
+// statement and anything but a positive integer is rejected.
+func parseSessionID(v interface{}) (int, error) {
+	switch id := v.(type) {
+	case float64:
+		if id >= 1 && id <= math.MaxInt32 && id == math.Trunc(id) {
+			return int(id), nil
+		}
+	case string:
+		id = strings.TrimSpace(id)
+		if n, err := strconv.ParseInt(id, 10, 32); err == nil && n >= 1 && strings.Trim(id, "0123456789") == "" {
+			return int(n), nil
+		}
+	}
+	return 0, fmt.Errorf("session_id must be a positive integer such as 53, got %v", v)
+}
+
+// killSession ends the session with the given ID on the instance behind
+// connection, rolling back its open transaction. It needs ALTER ANY
+// CONNECTION permission, and SQL Server refuses to kill the caller's own
+// session.
+func killSession(ctx context.Context, dm *DatabaseManager, connection string, sessionID int) (string, error) {
+	if !killAllowed() {
+		return "", fmt.Errorf("kill_session is disabled: set MSSQL_ALLOW_KILL=true to allow ending other sessions")
+	}
+
+	db, err := dm.getConnection(connection)
+	if err != nil {
+		return "", fmt.Errorf("database connection unavailable: %v", err)
+	}
+
+	timeout := queryTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, "KILL "+strconv.Itoa(sessionID)); err != nil {
+		return "", wrapQueryError(ctx, timeout, fmt.Sprintf("failed to kill session %d", sessionID), err)
+	}
+	return fmt.Sprintf("Session %d was killed. Its open transaction, if any, is being rolled back.", sessionID), nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSessionID(t *testing.T) {
+	for _, v := range []interface{}{float64(53), "53", " 53 "} {
+		id, err := parseSessionID(v)
+		assert.NoError(t, err)
+		assert.Equal(t, 53, id)
+	}
+
+	for _, v := range []interface{}{float64(0), float64(-1), 12.5, float64(1 << 40), "", "0", "+53", "53; SHUTDOWN", "0x35", true, nil} {
+		_, err := parseSessionID(v)
+		assert.Error(t, err, "%v should be rejected", v)
+	}
+}
+
+func TestKillSessionDisabled(t *testing.T) {
+	t.Setenv("MSSQL_ALLOW_KILL", "")
+	_, err := killSession(context.Background(), NewDatabaseManager(), "", 53)
+	assert.EqualError(t, err, "kill_session is disabled: set MSSQL_ALLOW_KILL=true to allow ending other sessions")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// maskText replaces every value of a masked column.
+const maskText = "***"
+
+// maskedColumns parses MSSQL_MASK_COLUMNS, a comma-separated list of column
+// names whose values are hidden in query output, into a set of lowercase
+// names. It returns nil when nothing is masked.
+func maskedColumns() map[string]bool {
+	var masked map[string]bool
+	for _, name := range strings.Split(os.Getenv("MSSQL_MASK_COLUMNS"), ",") {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "[]"))
+		if name == "" {
+			continue
+		}
+		if masked == nil {
+			masked = make(map[string]bool)
+		}
+		masked[name] = true
+	}
+	return masked
+}
+
+// columnMasker hides the values of MSSQL_MASK_COLUMNS columns as rows are
+// read. Columns are matched by the name the result set reports, so an alias
+// is only masked if the alias itself is listed.
+type columnMasker struct {
+	masked  map[string]bool
+	result  *queryResult
+	indexes []int
+}
+
+func newColumnMasker() *columnMasker {
+	return &columnMasker{masked: maskedColumns()}
+}
+
+// apply replaces the values of masked columns in values, a row of result, with
+// maskText. NULLs are masked too so the output does not reveal which rows
+// have a value.
+func (m *columnMasker) apply(result *queryResult, values []interface{}) {
+	if m.masked == nil {
+		return
+	}
+	if result != m.result {
+		m.result = result
+		m.indexes = m.indexes[:0]
+		for i, col := range result.columns {
+			if m.masked[strings.ToLower(col)] {
+				m.indexes = append(m.indexes, i)
+			}
+		}
+	}
+	for _, i := range m.indexes {
+		values[i] = maskText
+	}
+}
+
+// maskResults applies MSSQL_MASK_COLUMNS to every row of results.
+func maskResults(results []*queryResult) {
+	m := newColumnMasker()
+	for _, result := range results {
+		for _, row := range result.rows {
+			m.apply(result, row)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskResults(t *testing.T) {
+	t.Setenv("MSSQL_MASK_COLUMNS", " SSN, [email] ")
+	results := []*queryResult{
+		{set: 1, columns: []string{"id", "ssn", "Email"}, rows: [][]interface{}{{int64(1), "123-45-6789", nil}, {int64(2), "987-65-4321", "a@example.com"}}},
+		{set: 2, columns: []string{"email_address"}, rows: [][]interface{}{{"b@example.com"}}},
+	}
+	maskResults(results)
+
+	assert.Equal(t, [][]interface{}{{int64(1), maskText, maskText}, {int64(2), maskText, maskText}}, results[0].rows)
+	assert.Equal(t, [][]interface{}{{"b@example.com"}}, results[1].rows)
+
+	t.Setenv("MSSQL_MASK_COLUMNS", "")
+	assert.Nil(t, maskedColumns())
+}
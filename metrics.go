@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsPath = "/metrics"
+
+// Outcome label values for query metrics.
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var (
+	queriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mssql_mcp_queries_total",
+		Help: "Queries run through execute_sql, by statement type and outcome.",
+	}, []string{"statement", "outcome"})
+
+	queriesInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mssql_mcp_queries_in_flight",
+		Help: "Queries from execute_sql that are currently running.",
+	})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mssql_mcp_query_duration_seconds",
+		Help:    "Time spent running execute_sql queries, by statement type and outcome.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 4, 9),
+	}, []string{"statement", "outcome"})
+)
+
+// statementKeywords are the leading keywords reported as the statement label.
+// Anything else is reported as "other" to keep label cardinality bounded.
+var statementKeywords = map[string]string{
+	"select":   "select",
+	"with":     "select",
+	"insert":   "insert",
+	"update":   "update",
+	"delete":   "delete",
+	"merge":    "merge",
+	"exec":     "exec",
+	"execute":  "exec",
+	"create":   "ddl",
+	"alter":    "ddl",
+	"drop":     "ddl",
+	"truncate": "ddl",
+}
+
+// statementType classifies query by its first keyword, skipping leading
+// whitespace and comments.
+func statementType(query string) string {
+	rest := query
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n(;")
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				return "other"
+			}
+			rest = rest[end+1:]
+			continue
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				return "other"
+			}
+			rest = rest[end+2:]
+			continue
+		}
+		break
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end >= 0 {
+		rest = rest[:end]
+	}
+	if statement, ok := statementKeywords[strings.ToLower(rest)]; ok {
+		return statement
+	}
+	return "other"
+}
+
+// instrumentQuery wraps run so each call is counted and timed under the
+// statement type of query.
+func instrumentQuery(query string, run func(ctx context.Context) (string, error)) func(ctx context.Context) (string, error) {
+	statement := statementType(query)
+	return func(ctx context.Context) (string, error) {
+		queriesInFlight.Inc()
+		defer queriesInFlight.Dec()
+
+		start := time.Now()
+		result, err := run(ctx)
+
+		outcome := outcomeSuccess
+		if err != nil {
+			outcome = outcomeError
+		}
+		queriesTotal.WithLabelValues(statement, outcome).Inc()
+		queryDuration.WithLabelValues(statement, outcome).Observe(time.Since(start).Seconds())
+		return result, err
+	}
+}
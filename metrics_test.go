@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementType(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1":          "select",
+		"  select * from t": "select",
+		"WITH cte AS (SELECT 1) SELECT * FROM cte": "select",
+		"-- note\nINSERT INTO t VALUES (1)":        "insert",
+		"/* a */ update t set x = 1":               "update",
+		"DELETE FROM t":                            "delete",
+		"EXEC sp_who":                              "exec",
+		"CREATE TABLE t (id int)":                  "ddl",
+		"DECLARE @x int":                           "other",
+		"":                                         "other",
+		"-- only a comment":                        "other",
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, statementType(query), query)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// normalizeQuery collapses every run of whitespace in query to a single space
+// and trims it, so queries that differ only in layout compare equal. String
+// literals, quoted identifiers and comments are copied unchanged, and a line
+// break that ends a -- comment is kept so the code after it stays out of the
+// comment. Keyword case is left alone: telling keywords from unquoted
+// identifiers, which may be case-sensitive under the database's collation,
+// needs a full parser.
+func normalizeQuery(query string) string {
+	var out strings.Builder
+	runes := []rune(query)
+	separator := ""
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			if separator == "" {
+				separator = " "
+			}
+			i++
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteString(separator)
+		}
+		separator = ""
+
+		start := i
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			out.WriteString(strings.TrimRightFunc(string(runes[start:i]), unicode.IsSpace))
+			separator = "\n"
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+		case r == '\'' || r == '"' || r == '[':
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			i++
+			for i < len(runes) {
+				if runes[i] == closing {
+					// A doubled closing character is an escaped one.
+					if i+1 < len(runes) && runes[i+1] == closing {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i = min(i+1, len(runes))
+		default:
+			i++
+		}
+		out.WriteString(string(runes[start:i]))
+	}
+	return out.String()
+}
+
+// queryKey identifies query run on connection for caching and deduplication.
+// Queries that normalize to the same text share a key.
+func queryKey(connection, query string) string {
+	return normalizeConnectionName(connection) + "\x00" + normalizeQuery(query)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	assert.Equal(t, "SELECT a, b FROM t WHERE x = 1", normalizeQuery("  SELECT a,\n\tb\r\nFROM   t WHERE x = 1 \n"))
+	assert.Equal(t, normalizeQuery("SELECT *\nFROM t"), normalizeQuery("SELECT  *  FROM   t\n"))
+
+	// Whitespace inside literals, quoted identifiers and block comments is kept.
+	assert.Equal(t, "SELECT 'a  b', [my  col], \"x  y\" /* keep  this */ FROM t", normalizeQuery("SELECT 'a  b',  [my  col], \"x  y\"  /* keep  this */\nFROM t"))
+	assert.Equal(t, "SELECT 'it''s  here' FROM t", normalizeQuery("SELECT   'it''s  here'   FROM t"))
+	assert.NotEqual(t, normalizeQuery("SELECT * FROM t WHERE name = 'a b'"), normalizeQuery("SELECT * FROM t WHERE name = 'a  b'"))
+
+	// A line comment keeps its line break so the next line is not commented out.
+	assert.Equal(t, "SELECT 1 -- note\nFROM t", normalizeQuery("SELECT 1   -- note  \n\n   FROM t"))
+	assert.NotEqual(t, normalizeQuery("SELECT 1 -- note\nFROM t"), normalizeQuery("SELECT 1 -- note FROM t"))
+
+	// Unterminated literals are copied as they are.
+	assert.Equal(t, "SELECT 'open  ended", normalizeQuery("SELECT   'open  ended"))
+	assert.Equal(t, "", normalizeQuery(" \n\t "))
+}
+
+func TestQueryKey(t *testing.T) {
+	assert.Equal(t, queryKey("", "SELECT  1"), queryKey("default", "SELECT 1\n"))
+	assert.NotEqual(t, queryKey("", "SELECT 1"), queryKey("reporting", "SELECT 1"))
+	assert.NotEqual(t, queryKey("", "SELECT 'a'"), queryKey("", "SELECT 'A'"))
+}
+
+func TestDistinctHistory(t *testing.T) {
+	h := newQueryHistory(10)
+	h.record("", "SELECT 1", time.Now(), 1, nil)
+	h.record("", "SELECT 'x  y'", time.Now(), 1, nil)
+	h.record("", "SELECT\n  1", time.Now(), 2, nil)
+	h.record("reporting", "SELECT 1", time.Now(), 3, nil)
+
+	entries := distinctHistory(h.recent(0))
+	require.Len(t, entries, 3)
+	assert.Equal(t, "reporting", entries[0].connection)
+	assert.Equal(t, 2, entries[1].rows)
+	assert.Equal(t, "SELECT 'x  y'", entries[2].query)
+
+	// The limit counts distinct queries, not runs.
+	entries = h.list(2, true)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "reporting", entries[0].connection)
+	assert.Equal(t, 2, entries[1].rows)
+	assert.Len(t, h.list(2, false), 2)
+	assert.Len(t, h.list(0, false), 4)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// outputLimitNote returns the trailer appended when output was cut to fit
+// MSSQL_MAX_OUTPUT_BYTES.
+func outputLimitNote(limit, shown, total int) string {
+	return fmt.Sprintf("\n... (OUTPUT TRUNCATED: the result exceeded MSSQL_MAX_OUTPUT_BYTES (%d bytes); showing %d of %d rows)", limit, shown, total)
+}
+
+// keepRows returns copies of results holding only the first keep rows in
+// order across every set. Sets after the one the cut falls in are dropped.
+func keepRows(results []*queryResult, keep int) []*queryResult {
+	var kept []*queryResult
+	for _, result := range results {
+		if keep == 0 && len(kept) > 0 && len(result.rows) > 0 {
+			break
+		}
+		trimmed := *result
+		if len(trimmed.rows) > keep {
+			trimmed.rows = trimmed.rows[:keep]
+		}
+		keep -= len(trimmed.rows)
+		kept = append(kept, &trimmed)
+	}
+	return kept
+}
+
+// fitOutput re-renders results with as many leading rows as fit in limit
+// bytes, so the cut always falls on a row boundary and JSON stays valid. It
+// returns the output and the note to append in place of the usual truncation
+// note. If not even the headers fit, they are returned without any rows.
+func fitOutput(results []*queryResult, format string, opts queryOptions, limit int) (string, string, error) {
+	total := 0
+	for _, result := range results {
+		total += len(result.rows)
+	}
+
+	var formatErr error
+	// The largest row count whose output still fits; output size grows
+	// with every row, so a binary search finds it.
+	shown := sort.Search(total+1, func(keep int) bool {
+		output, err := formatResults(keepRows(results, keep), format, opts)
+		if err != nil {
+			formatErr = err
+			return true
+		}
+		return len(output) > limit
+	}) - 1
+	if formatErr != nil {
+		return "", "", formatErr
+	}
+	shown = max(shown, 0)
+
+	output, err := formatResults(keepRows(results, shown), format, opts)
+	if err != nil {
+		return "", "", err
+	}
+	return output, outputLimitNote(limit, shown, total), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepRows(t *testing.T) {
+	results := []*queryResult{
+		{set: 1, columns: []string{"a"}, rows: [][]interface{}{{1}, {2}}},
+		{set: 2, columns: []string{"b"}, rows: [][]interface{}{{3}, {4}}},
+	}
+
+	kept := keepRows(results, 3)
+	require.Len(t, kept, 2)
+	assert.Len(t, kept[0].rows, 2)
+	assert.Len(t, kept[1].rows, 1)
+
+	kept = keepRows(results, 2)
+	require.Len(t, kept, 1)
+	assert.Len(t, kept[0].rows, 2)
+
+	kept = keepRows(results, 0)
+	require.Len(t, kept, 1)
+	assert.Empty(t, kept[0].rows)
+
+	// The originals are untouched.
+	assert.Len(t, results[1].rows, 2)
+}
+
+func TestFitOutput(t *testing.T) {
+	rows := make([][]interface{}, 100)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i), "some reasonably wide value"}
+	}
+	results := []*queryResult{{set: 1, columns: []string{"id", "value"}, types: []string{"INT", "NVARCHAR"}, rows: rows}}
+	opts := queryOptions{nullText: defaultNullText}
+
+	for _, format := range []string{formatText, formatJSON, formatCSV} {
+		output, note, err := fitOutput(results, format, opts, 500)
+		require.NoError(t, err, format)
+		assert.LessOrEqual(t, len(output), 500, format)
+		assert.Contains(t, note, "OUTPUT TRUNCATED", format)
+		assert.Contains(t, note, "of 100 rows", format)
+		if format == formatJSON {
+			var parsed []map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(output), &parsed))
+			assert.NotEmpty(t, parsed)
+		} else {
+			assert.Equal(t, byte('\n'), output[len(output)-1], format)
+		}
+	}
+
+	output, note, err := fitOutput(results, formatText, opts, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "Query executed successfully. No rows returned.", output)
+	assert.Contains(t, note, "showing 0 of 100 rows")
+}
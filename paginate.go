@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const defaultPageSize = 50
+
+// tableRef is a table resolved against the catalog, with its columns in
+// ordinal order.
+type tableRef struct {
+	schema  string
+	name    string
+	columns []string
+}
+
+// splitTableName splits "schema.table" into its parts, unquoting each one.
+// Only dots outside brackets and double quotes separate parts, so
+// [my.schema].[t] and dbo.[a.b] keep the dots in their names. The schema is
+// empty when the name is unqualified; a database prefix is ignored.
+func splitTableName(table string) (string, string) {
+	runes := []rune(table)
+	var parts []string
+	start := 0
+	for _, token := range scanSQLTokenSpans(table, true) {
+		if token.text == "." {
+			parts = append(parts, unquoteIdentifier(string(runes[start:token.start])))
+			start = token.end
+		}
+	}
+	parts = append(parts, unquoteIdentifier(string(runes[start:])))
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// resolveTable looks table up in INFORMATION_SCHEMA so that only names that
+// really exist are ever interpolated into SQL. Unqualified names are looked
+// up in MSSQL_DEFAULT_SCHEMA when it is set, and in every schema otherwise.
+// Tables outside MSSQL_ALLOWED_TABLES are rejected.
+func resolveTable(ctx context.Context, dm *DatabaseManager, connection, table string) (*tableRef, error) {
+	schema, name := splitTableName(strings.TrimSpace(table))
+	if name == "" {
+		return nil, fmt.Errorf("table name is empty")
+	}
+	if schema == "" {
+		schema = defaultSchema()
+	}
+
+	query := `SELECT TABLE_SCHEMA, TABLE_NAME, COLUMN_NAME
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_NAME = @name`
+	args := []interface{}{sql.Named("name", name)}
+	if schema != "" {
+		query += " AND TABLE_SCHEMA = @schema"
+		args = append(args, sql.Named("schema", schema))
+	}
+	query += "\nORDER BY TABLE_SCHEMA, ORDINAL_POSITION"
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection, maxRows: schemaMaxRows}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.rows) == 0 {
+		return nil, fmt.Errorf("table '%s' not found", table)
+	}
+
+	ref := &tableRef{schema: formatValue(result.rows[0][0]), name: formatValue(result.rows[0][1])}
+	for _, row := range result.rows {
+		if formatValue(row[0]) != ref.schema {
+			return nil, fmt.Errorf("table name '%s' is ambiguous; qualify it with a schema, e.g. %s.%s", table, ref.schema, ref.name)
+		}
+		ref.columns = append(ref.columns, formatValue(row[2]))
+	}
+	if err := checkTableAllowed(ref.schema, ref.name); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// column returns the catalog spelling of name, matched case-insensitively.
+func (t *tableRef) column(name string) (string, bool) {
+	for _, col := range t.columns {
+		if strings.EqualFold(col, unquoteIdentifier(name)) {
+			return col, true
+		}
+	}
+	return "", false
+}
+
+// quotedName returns the schema-qualified name quoted for use in SQL text.
+func (t *tableRef) quotedName() (string, error) {
+	schema, err := quoteIdentifier(t.schema)
+	if err != nil {
+		return "", err
+	}
+	name, err := quoteIdentifier(t.name)
+	if err != nil {
+		return "", err
+	}
+	return schema + "." + name, nil
+}
+
+// pageRequest describes one page of a query_table call.
+type pageRequest struct {
+	table      string
+	orderBy    string
+	descending bool
+	limit      int
+	offset     int
+	format     string
+}
+
+// queryTable returns one page of rows from a table using OFFSET/FETCH. One
+// row beyond the page is requested to tell whether more rows exist.
+func queryTable(ctx context.Context, dm *DatabaseManager, connection string, page pageRequest) (string, error) {
+	if page.format != formatText && page.format != formatJSON {
+		return "", fmt.Errorf("unsupported format '%s': expected %s or %s", page.format, formatText, formatJSON)
+	}
+	if page.limit <= 0 {
+		page.limit = defaultPageSize
+	}
+	if page.offset < 0 {
+		return "", fmt.Errorf("offset must not be negative")
+	}
+
+	ref, err := resolveTable(ctx, dm, connection, page.table)
+	if err != nil {
+		return "", err
+	}
+
+	orderBy := ref.columns[0]
+	if page.orderBy != "" {
+		col, ok := ref.column(page.orderBy)
+		if !ok {
+			return "", fmt.Errorf("column '%s' does not exist in %s.%s (columns: %s)", page.orderBy, ref.schema, ref.name, strings.Join(ref.columns, ", "))
+		}
+		orderBy = col
+	}
+	direction := "ASC"
+	if page.descending {
+		direction = "DESC"
+	}
+
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+	orderColumn, err := quoteIdentifier(orderBy)
+	if err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY %s %s OFFSET @offset ROWS FETCH NEXT @fetch ROWS ONLY",
+		tableName, orderColumn, direction)
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection, maxRows: page.limit + 1}, query,
+		sql.Named("offset", page.offset), sql.Named("fetch", page.limit+1))
+	if err != nil {
+		return "", err
+	}
+	return formatTablePage(result, page, orderBy, direction)
+}
+
+// formatTablePage renders the rows queryTable read, masked like every other
+// result and split into the page and whether more rows follow.
+func formatTablePage(result *queryResult, page pageRequest, orderBy, direction string) (string, error) {
+	hasMore := len(result.rows) > page.limit
+	if hasMore {
+		result.rows = result.rows[:page.limit]
+	}
+	maskResults([]*queryResult{result})
+	returned := len(result.rows)
+
+	if page.format == formatJSON {
+		rows, err := formatJSONRows(result.columns, result.rows)
+		if err != nil {
+			return "", err
+		}
+		meta, err := json.Marshal(map[string]interface{}{
+			"returned": returned,
+			"offset":   page.offset,
+			"limit":    page.limit,
+			"has_more": hasMore,
+		})
+		if err != nil {
+			return "", err
+		}
+		// Splice the already-encoded rows in so column order is kept.
+		return strings.TrimSuffix(string(meta), "}") + `,"rows":` + rows + "}", nil
+	}
+
+	summary := fmt.Sprintf("Returned %d row(s) at offset %d, ordered by %s %s. More rows available: %t.",
+		returned, page.offset, orderBy, direction, hasMore)
+	if returned == 0 {
+		return summary, nil
+	}
+	return formatTable(result.columns, result.types, result.rows, defaultNullText) + "\n" + summary, nil
+}
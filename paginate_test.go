@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTableName(t *testing.T) {
+	schema, name := splitTableName("dbo.Users")
+	assert.Equal(t, "dbo", schema)
+	assert.Equal(t, "Users", name)
+
+	schema, name = splitTableName("[sales].[Order Lines]")
+	assert.Equal(t, "sales", schema)
+	assert.Equal(t, "Order Lines", name)
+
+	schema, name = splitTableName("Users")
+	assert.Equal(t, "", schema)
+	assert.Equal(t, "Users", name)
+
+	// Dots inside quoted parts belong to the name.
+	schema, name = splitTableName("[my.schema].[t]")
+	assert.Equal(t, "my.schema", schema)
+	assert.Equal(t, "t", name)
+
+	schema, name = splitTableName("dbo.[a.b]")
+	assert.Equal(t, "dbo", schema)
+	assert.Equal(t, "a.b", name)
+
+	schema, name = splitTableName(`"x.y"."odd]]name"`)
+	assert.Equal(t, "x.y", schema)
+	assert.Equal(t, "odd]]name", name)
+
+	schema, name = splitTableName("[a.b]")
+	assert.Equal(t, "", schema)
+	assert.Equal(t, "a.b", name)
+
+	schema, name = splitTableName("Sales.dbo.[Order.Lines]")
+	assert.Equal(t, "dbo", schema)
+	assert.Equal(t, "Order.Lines", name)
+}
+
+func TestQuotedName(t *testing.T) {
+	ref := &tableRef{schema: "sales", name: "odd]name"}
+	quoted, err := ref.quotedName()
+	assert.NoError(t, err)
+	assert.Equal(t, "[sales].[odd]]name]", quoted)
+}
+
+func TestFormatTablePageMasksColumns(t *testing.T) {
+	t.Setenv("MSSQL_MASK_COLUMNS", "ssn")
+	newResult := func() *queryResult {
+		return &queryResult{
+			columns: []string{"id", "ssn"},
+			types:   []string{"INT", "VARCHAR"},
+			rows:    [][]interface{}{{int64(1), "123-45-6789"}, {int64(2), "987-65-4321"}},
+		}
+	}
+
+	output, err := formatTablePage(newResult(), pageRequest{limit: 1, format: formatText}, "id", "ASC")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "123-45-6789")
+	assert.Contains(t, output, maskText)
+	assert.Contains(t, output, "More rows available: true")
+
+	output, err = formatTablePage(newResult(), pageRequest{limit: 5, format: formatJSON}, "id", "ASC")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "987-65-4321")
+	assert.Contains(t, output, `"has_more":false`)
+}
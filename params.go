@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// placeholderPattern matches the positional @p1, @p2, ... placeholders that
+// go-mssqldb binds ordinary query arguments to.
+var placeholderPattern = regexp.MustCompile(`(?i)@p(\d+)\b`)
+
+// countPlaceholders returns the highest @pN index referenced by query.
+func countPlaceholders(query string) int {
+	highest := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatch(query, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// coerceParam converts a decoded JSON value into a driver argument. JSON
+// numbers without a fractional part are passed as int64 so they bind as
+// integers rather than floats. An object of the form {"value": ..., "type":
+// ...} binds its value as the hinted SQL Server type.
+func coerceParam(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil, string, bool:
+		return val, nil
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val), nil
+		}
+		return val, nil
+	case map[string]interface{}:
+		return typedParam(val)
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %T: only strings, numbers, booleans, null and {\"value\", \"type\"} objects are allowed", v)
+	}
+}
+
+// buildQueryArgs validates params against the placeholders in query and
+// converts them into arguments for QueryContext.
+func buildQueryArgs(query string, params []interface{}) ([]interface{}, error) {
+	expected := countPlaceholders(query)
+	if expected != len(params) {
+		return nil, fmt.Errorf("query references %d parameter(s) (@p1..@p%d) but %d were provided", expected, expected, len(params))
+	}
+
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		arg, err := coerceParam(p)
+		if err != nil {
+			return nil, fmt.Errorf("parameter @p%d: %v", i+1, err)
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// requestQueryArgs binds the optional params argument of a tool call to the
+// placeholders in query. Queries without params are passed through untouched
+// so scripts that DECLARE their own @p variables keep working.
+func requestQueryArgs(request mcp.CallToolRequest, query string) ([]interface{}, error) {
+	raw, ok := request.GetArguments()["params"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	params, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("'params' must be an array")
+	}
+	return buildQueryArgs(query, params)
+}
+
+// requestTimeout reads the optional timeout_seconds argument of a tool call.
+// It returns zero when the argument is absent, so the global timeout applies,
+// and clamps larger values to MSSQL_MAX_QUERY_TIMEOUT_SECONDS.
+func requestTimeout(request mcp.CallToolRequest) (time.Duration, error) {
+	raw, ok := request.GetArguments()["timeout_seconds"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	seconds, ok := raw.(float64)
+	if !ok || seconds != math.Trunc(seconds) || seconds < 1 {
+		return 0, errors.New("'timeout_seconds' must be a positive whole number of seconds")
+	}
+	if ceiling := maxCallTimeout(); seconds > ceiling.Seconds() {
+		return ceiling, nil
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/golang-sql/civil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildQueryArgs(t *testing.T) {
+	args, err := buildQueryArgs(
+		"SELECT * FROM t WHERE id = @p1 AND name = @p2 AND active = @p3 AND score > @p4 AND note IS @p5 OR id = @P1",
+		[]interface{}{float64(42), "alice", true, 1.5, nil},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(42), "alice", true, 1.5, nil}, args)
+
+	_, err = buildQueryArgs("SELECT @p1, @p2", []interface{}{"only one"})
+	assert.ErrorContains(t, err, "2 parameter(s)")
+
+	_, err = buildQueryArgs("SELECT 1", []interface{}{"extra"})
+	assert.ErrorContains(t, err, "0 parameter(s)")
+
+	_, err = buildQueryArgs("SELECT @p1", []interface{}{map[string]interface{}{"a": 1}})
+	assert.ErrorContains(t, err, "@p1")
+
+	args, err = buildQueryArgs("SELECT @p10", make([]interface{}, 10))
+	require.NoError(t, err)
+	assert.Len(t, args, 10)
+}
+
+func TestTypedParams(t *testing.T) {
+	args, err := buildQueryArgs("SELECT @p1, @p2, @p3, @p4", []interface{}{
+		map[string]interface{}{"value": "2024-01-31", "type": "date"},
+		map[string]interface{}{"value": "6F9619FF-8B86-D011-B42D-00C04FC964FF", "type": "UniqueIdentifier"},
+		map[string]interface{}{"value": "12345678901234567890.25", "type": "decimal"},
+		map[string]interface{}{"value": nil, "type": "int"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, civil.Date{Year: 2024, Month: time.January, Day: 31}, args[0])
+	assert.Equal(t, mssql.UniqueIdentifier{0x6F, 0x96, 0x19, 0xFF, 0x8B, 0x86, 0xD0, 0x11, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}, args[1])
+	assert.Equal(t, "12345678901234567890.25", args[2])
+	assert.Nil(t, args[3])
+
+	cases := []struct {
+		param map[string]interface{}
+		want  interface{}
+	}{
+		{map[string]interface{}{"value": float64(7), "type": "int"}, int64(7)},
+		{map[string]interface{}{"value": "9007199254740993", "type": "bigint"}, int64(9007199254740993)},
+		{map[string]interface{}{"value": float64(42), "type": "nvarchar"}, "42"},
+		{map[string]interface{}{"value": float64(1), "type": "bit"}, true},
+		{map[string]interface{}{"value": "2024-01-31T13:45:00", "type": "datetime"},
+			mssql.DateTime1(time.Date(2024, time.January, 31, 13, 45, 0, 0, time.UTC))},
+	}
+	for _, c := range cases {
+		got, err := coerceParam(c.param)
+		require.NoError(t, err, c.param)
+		assert.Equal(t, c.want, got, c.param)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"value": "31/01/2024", "type": "date"},
+		{"value": "not-a-guid", "type": "uniqueidentifier"},
+		{"value": float64(1 << 40), "type": "int"},
+		{"value": "1e5", "type": "decimal"},
+		{"value": "x", "type": "xml"},
+		{"type": "date"},
+	} {
+		_, err := coerceParam(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	t.Setenv("MSSQL_QUERY_TIMEOUT_SECONDS", "")
+	t.Setenv("MSSQL_MAX_QUERY_TIMEOUT_SECONDS", "120")
+
+	timeout, err := requestTimeout(callWithArguments(nil))
+	require.NoError(t, err)
+	assert.Zero(t, timeout)
+
+	timeout, err = requestTimeout(callWithArguments(map[string]interface{}{"timeout_seconds": float64(90)}))
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	// Runaway values are clamped to the ceiling.
+	timeout, err = requestTimeout(callWithArguments(map[string]interface{}{"timeout_seconds": float64(86400)}))
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, timeout)
+
+	for _, invalid := range []interface{}{float64(0), float64(-5), 1.5, "60"} {
+		_, err = requestTimeout(callWithArguments(map[string]interface{}{"timeout_seconds": invalid}))
+		assert.ErrorContains(t, err, "'timeout_seconds' must be a positive whole number", invalid)
+	}
+
+	// The ceiling never undercuts the global timeout.
+	t.Setenv("MSSQL_QUERY_TIMEOUT_SECONDS", "300")
+	assert.Equal(t, 5*time.Minute, maxCallTimeout())
+}
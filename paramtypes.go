@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/golang-sql/civil"
+)
+
+// decimalPattern matches the exact decimal literals accepted for a decimal
+// parameter.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+// datetimeLayouts are the forms accepted for a datetime parameter, tried in
+// order.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// paramTypes converts a hinted parameter value into the driver argument that
+// binds as that SQL Server type.
+var paramTypes = map[string]func(v interface{}) (interface{}, error){
+	"int": func(v interface{}) (interface{}, error) {
+		n, err := integerParam(v)
+		if err == nil && (n < math.MinInt32 || n > math.MaxInt32) {
+			err = fmt.Errorf("%d is out of range for int", n)
+		}
+		return n, err
+	},
+	"bigint": func(v interface{}) (interface{}, error) {
+		return integerParam(v)
+	},
+	// The driver has no decimal parameter type, so the value is sent as its
+	// exact text and converted by the server rather than rounded through a
+	// float.
+	"decimal": func(v interface{}) (interface{}, error) {
+		var text string
+		switch val := v.(type) {
+		case float64:
+			text = strconv.FormatFloat(val, 'f', -1, 64)
+		case string:
+			text = strings.TrimSpace(val)
+		}
+		if !decimalPattern.MatchString(text) {
+			return nil, fmt.Errorf("%v is not a decimal number", v)
+		}
+		return text, nil
+	},
+	"nvarchar": func(v interface{}) (interface{}, error) {
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		}
+		return nil, fmt.Errorf("%v cannot be sent as nvarchar", v)
+	},
+	"date": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("a date must be a string such as 2024-01-31")
+		}
+		d, err := civil.ParseDate(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date such as 2024-01-31", s)
+		}
+		return d, nil
+	},
+	"datetime": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("a datetime must be a string such as 2024-01-31T13:45:00")
+		}
+		for _, layout := range datetimeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+				return mssql.DateTime1(t), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a datetime such as 2024-01-31T13:45:00", s)
+	},
+	"bit": func(v interface{}) (interface{}, error) {
+		switch val := v.(type) {
+		case bool:
+			return val, nil
+		case float64:
+			if val == 0 || val == 1 {
+				return val == 1, nil
+			}
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not a bit: expected true, false, 1 or 0", v)
+	},
+	"uniqueidentifier": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("a uniqueidentifier must be a string")
+		}
+		var id mssql.UniqueIdentifier
+		if err := id.Scan(strings.TrimSpace(s)); err != nil {
+			return nil, fmt.Errorf("%q is not a uniqueidentifier such as 6F9619FF-8B86-D011-B42D-00C04FC964FF", s)
+		}
+		return id, nil
+	},
+}
+
+// integerParam reads a whole JSON number, or a string holding one, as int64.
+func integerParam(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int64(val), nil
+		}
+	case string:
+		if n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a whole number", v)
+}
+
+// typedParam converts a parameter given as {"value": ..., "type": ...} into a
+// driver argument of the hinted SQL Server type, so the driver does not infer
+// a type that forces an implicit conversion. A null value stays NULL.
+func typedParam(param map[string]interface{}) (interface{}, error) {
+	typeName, _ := param["type"].(string)
+	convert, ok := paramTypes[strings.ToLower(strings.TrimSpace(typeName))]
+	if !ok {
+		names := make([]string, 0, len(paramTypes))
+		for name := range paramTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported type hint %q: expected one of %s", typeName, strings.Join(names, ", "))
+	}
+	value, ok := param["value"]
+	if !ok {
+		return nil, fmt.Errorf("typed parameter is missing its value")
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return convert(value)
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/encoding"
+)
+
+// formatParquet is the columnar file format save_query_result can write in
+// addition to CSV and JSON.
+const formatParquet = "parquet"
+
+// parquetColumnSpec is what the Parquet schema of one column is derived from.
+type parquetColumnSpec struct {
+	name string
+	// dbType is the SQL Server type name, e.g. DECIMAL.
+	dbType    string
+	precision int64
+	scale     int64
+	nullable  bool
+	// masked columns hold maskText in place of their values.
+	masked bool
+}
+
+// parquetColumnSpecs describes the columns of result for parquetColumns, from
+// the driver's column metadata. Column names are made unique and non-empty as
+// for JSON keys. Nullability defaults to true when the driver cannot say.
+func parquetColumnSpecs(result *queryResult) []parquetColumnSpec {
+	masked := maskedColumns()
+	names := jsonColumnNames(result.columns)
+	specs := make([]parquetColumnSpec, len(result.columns))
+	for i, name := range names {
+		spec := parquetColumnSpec{name: name, dbType: result.types[i], nullable: true, masked: masked[strings.ToLower(result.columns[i])]}
+		if i < len(result.columnTypes) {
+			ct := result.columnTypes[i]
+			if nullable, ok := ct.Nullable(); ok {
+				spec.nullable = nullable
+			}
+			if precision, scale, ok := ct.DecimalSize(); ok {
+				spec.precision, spec.scale = precision, scale
+			}
+		}
+		specs[i] = spec
+	}
+	return specs
+}
+
+// parquetColumn is one column of a Parquet file: its schema node and how a
+// non-NULL driver value becomes a Parquet value.
+type parquetColumn struct {
+	parquet.Node
+	name  string
+	value func(v interface{}) (parquet.Value, error)
+}
+
+func (c parquetColumn) Name() string { return c.name }
+
+func (c parquetColumn) Value(reflect.Value) reflect.Value { return reflect.Value{} }
+
+// parquetSchema is the root of a Parquet schema with its columns in result
+// order. parquet.Group would sort them by name.
+type parquetSchema []parquetColumn
+
+func (s parquetSchema) ID() int                     { return 0 }
+func (s parquetSchema) String() string              { return parquet.NewSchema("result", s).String() }
+func (s parquetSchema) Type() parquet.Type          { return parquet.Group{}.Type() }
+func (s parquetSchema) Optional() bool              { return false }
+func (s parquetSchema) Repeated() bool              { return false }
+func (s parquetSchema) Required() bool              { return true }
+func (s parquetSchema) Leaf() bool                  { return false }
+func (s parquetSchema) Encoding() encoding.Encoding { return nil }
+func (s parquetSchema) Compression() compress.Codec { return nil }
+func (s parquetSchema) GoType() reflect.Type        { return parquet.Group{}.GoType() }
+
+func (s parquetSchema) Fields() []parquet.Field {
+	fields := make([]parquet.Field, len(s))
+	for i := range s {
+		fields[i] = s[i]
+	}
+	return fields
+}
+
+// maxParquetDecimalPrecision is the widest precision SQL Server allows.
+const maxParquetDecimalPrecision = 38
+
+// parquetColumnOf maps a SQL Server column to a Parquet column, keeping its
+// type: integers, bits and floats natively, exact numerics as DECIMAL,
+// dates, times and timestamps as their logical types, uniqueidentifier as
+// UUID and binary as raw bytes. Everything else, and masked columns, is
+// written as a string rendered as the text formats would show it.
+func parquetColumnOf(spec parquetColumnSpec) parquetColumn {
+	column := parquetColumn{name: spec.name}
+	switch dbType := spec.dbType; {
+	case spec.masked:
+		column.Node, column.value = parquet.String(), parquetString(dbType)
+	case dbType == "TINYINT" || dbType == "SMALLINT" || dbType == "INT":
+		column.Node = parquet.Int(32)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			n, ok := v.(int64)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected an integer, got %T", v)
+			}
+			return parquet.Int32Value(int32(n)), nil
+		}
+	case dbType == "BIGINT":
+		column.Node = parquet.Int(64)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			n, ok := v.(int64)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected an integer, got %T", v)
+			}
+			return parquet.Int64Value(n), nil
+		}
+	case dbType == "BIT":
+		column.Node = parquet.Leaf(parquet.BooleanType)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a bit, got %T", v)
+			}
+			return parquet.BooleanValue(b), nil
+		}
+	case dbType == "FLOAT" || dbType == "REAL":
+		column.Node = parquet.Leaf(parquet.DoubleType)
+		if dbType == "REAL" {
+			column.Node = parquet.Leaf(parquet.FloatType)
+		}
+		column.value = func(v interface{}) (parquet.Value, error) {
+			f, ok := v.(float64)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a float, got %T", v)
+			}
+			if dbType == "REAL" {
+				return parquet.FloatValue(float32(f)), nil
+			}
+			return parquet.DoubleValue(f), nil
+		}
+	case isDecimalType(dbType):
+		precision, scale := spec.precision, spec.scale
+		switch {
+		case dbType == "MONEY":
+			precision, scale = 19, 4
+		case dbType == "SMALLMONEY":
+			precision, scale = 10, 4
+		case precision <= 0 || precision > maxParquetDecimalPrecision:
+			precision = maxParquetDecimalPrecision
+		}
+		column.Node, column.value = parquetDecimal(int(precision), int(scale))
+	case dbType == "DATE":
+		column.Node = parquet.Date()
+		column.value = func(v interface{}) (parquet.Value, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a date, got %T", v)
+			}
+			// Days since the Unix epoch, rounding down for earlier dates.
+			seconds := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix()
+			days := seconds / 86400
+			if seconds%86400 != 0 && seconds < 0 {
+				days--
+			}
+			return parquet.Int32Value(int32(days)), nil
+		}
+	case dbType == "TIME":
+		column.Node = parquet.Time(parquet.Microsecond)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a time, got %T", v)
+			}
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			return parquet.Int64Value(t.Sub(midnight).Microseconds()), nil
+		}
+	case dbType == "DATETIME" || dbType == "DATETIME2" || dbType == "SMALLDATETIME" || dbType == "DATETIMEOFFSET":
+		// Only datetimeoffset values are instants; the others are wall
+		// clock times with no zone and are stored as such.
+		offset := dbType == "DATETIMEOFFSET"
+		column.Node = parquet.TimestampAdjusted(parquet.Microsecond, offset)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected a datetime, got %T", v)
+			}
+			if !offset {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			}
+			return parquet.Int64Value(t.UnixMicro()), nil
+		}
+	case dbType == "UNIQUEIDENTIFIER":
+		column.Node = parquet.UUID()
+		column.value = func(v interface{}) (parquet.Value, error) {
+			var id mssql.UniqueIdentifier
+			if err := id.Scan(v); err != nil {
+				return parquet.Value{}, err
+			}
+			return parquet.FixedLenByteArrayValue(id[:]), nil
+		}
+	case isBinaryType(dbType):
+		column.Node = parquet.Leaf(parquet.ByteArrayType)
+		column.value = func(v interface{}) (parquet.Value, error) {
+			b, ok := v.([]byte)
+			if !ok {
+				return parquet.Value{}, fmt.Errorf("expected binary, got %T", v)
+			}
+			return parquet.ByteArrayValue(b), nil
+		}
+	default:
+		column.Node, column.value = parquet.String(), parquetString(dbType)
+	}
+
+	if spec.nullable {
+		column.Node = parquet.Optional(column.Node)
+	}
+	return column
+}
+
+// parquetString writes a value as the text formats render it.
+func parquetString(dbType string) func(v interface{}) (parquet.Value, error) {
+	return func(v interface{}) (parquet.Value, error) {
+		return parquet.ByteArrayValue([]byte(formatValue(normalizeValue(v, dbType)))), nil
+	}
+}
+
+// parquetDecimal returns a DECIMAL node of the given precision and scale and
+// a converter from the driver's exact decimal text. Precisions up to 18 fit
+// an INT64; wider ones use a fixed-length big-endian two's complement array,
+// as the Parquet format specifies.
+func parquetDecimal(precision, scale int) (parquet.Node, func(v interface{}) (parquet.Value, error)) {
+	size := 0
+	baseType := parquet.Int64Type
+	if precision > 18 {
+		// The smallest byte count whose signed range holds 10^precision.
+		limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+		size = (limit.BitLen() + 1 + 7) / 8
+		baseType = parquet.FixedLenByteArrayType(size)
+	}
+
+	return parquet.Decimal(scale, precision, baseType), func(v interface{}) (parquet.Value, error) {
+		unscaled, err := unscaledDecimal(v, scale)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		if size == 0 {
+			return parquet.Int64Value(unscaled.Int64()), nil
+		}
+		return parquet.FixedLenByteArrayValue(twosComplement(unscaled, size)), nil
+	}
+}
+
+// unscaledDecimal parses decimal text such as "-12.50" as an integer count of
+// 10^-scale units, e.g. -1250 for scale 2.
+func unscaledDecimal(v interface{}, scale int) (*big.Int, error) {
+	var text string
+	switch d := v.(type) {
+	case []byte:
+		text = string(d)
+	case string:
+		text = d
+	default:
+		return nil, fmt.Errorf("expected a decimal, got %T", v)
+	}
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if len(fraction) > scale {
+		return nil, fmt.Errorf("decimal %s has more than %d digits after the point", text, scale)
+	}
+	unscaled, ok := new(big.Int).SetString(whole+fraction+strings.Repeat("0", scale-len(fraction)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", text)
+	}
+	return unscaled, nil
+}
+
+// twosComplement encodes n big-endian in size bytes.
+func twosComplement(n *big.Int, size int) []byte {
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(size*8)), n)
+	}
+	b := n.Bytes()
+	out := make([]byte, size)
+	copy(out[size-len(b):], b)
+	return out
+}
+
+// parquetFileWriter writes rows of driver values to a Parquet file.
+type parquetFileWriter struct {
+	columns parquetSchema
+	writer  *parquet.Writer
+	row     parquet.Row
+}
+
+// newParquetFileWriter starts a Snappy-compressed Parquet file on out with
+// one column per spec.
+func newParquetFileWriter(out io.Writer, specs []parquetColumnSpec) *parquetFileWriter {
+	columns := make(parquetSchema, len(specs))
+	for i, spec := range specs {
+		columns[i] = parquetColumnOf(spec)
+	}
+	schema := parquet.NewSchema("result", columns)
+	return &parquetFileWriter{
+		columns: columns,
+		writer:  parquet.NewWriter(out, schema, parquet.Compression(&parquet.Snappy)),
+		row:     make(parquet.Row, len(columns)),
+	}
+}
+
+// write appends one row of driver values.
+func (w *parquetFileWriter) write(values []interface{}) error {
+	for i, column := range w.columns {
+		definition := 0
+		if column.Optional() {
+			definition = 1
+		}
+		if values[i] == nil {
+			if !column.Optional() {
+				return fmt.Errorf("column %q is declared NOT NULL but returned NULL", column.name)
+			}
+			w.row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+		value, err := column.value(values[i])
+		if err != nil {
+			return fmt.Errorf("failed to convert column %q for Parquet: %v", column.name, err)
+		}
+		w.row[i] = value.Level(0, definition, i)
+	}
+	if _, err := w.writer.WriteRows([]parquet.Row{w.row}); err != nil {
+		return fmt.Errorf("failed to write Parquet row: %v", err)
+	}
+	return nil
+}
+
+// close writes the Parquet footer. The underlying writer is not closed.
+func (w *parquetFileWriter) close() error {
+	if err := w.writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+	return nil
+}
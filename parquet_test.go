@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetFileWriter(t *testing.T) {
+	specs := []parquetColumnSpec{
+		{name: "id", dbType: "INT"},
+		{name: "total", dbType: "DECIMAL", precision: 10, scale: 2, nullable: true},
+		{name: "big", dbType: "NUMERIC", precision: 38, scale: 0, nullable: true},
+		{name: "placed", dbType: "DATETIME2", nullable: true},
+		{name: "on", dbType: "DATE", nullable: true},
+		{name: "note", dbType: "NVARCHAR", nullable: true},
+		{name: "active", dbType: "BIT"},
+	}
+	placed := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w := newParquetFileWriter(&buf, specs)
+	require.NoError(t, w.write([]interface{}{int64(1), []byte("-12.50"), []byte("-1"), placed, placed, "hello", true}))
+	require.NoError(t, w.write([]interface{}{int64(2), nil, nil, nil, nil, nil, false}))
+	require.NoError(t, w.close())
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), f.NumRows())
+
+	fields := f.Schema().Fields()
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name()
+	}
+	// Columns keep result order rather than being sorted by name.
+	assert.Equal(t, []string{"id", "total", "big", "placed", "on", "note", "active"}, names)
+	assert.True(t, fields[0].Required())
+	assert.True(t, fields[1].Optional())
+	assert.Equal(t, "DECIMAL(10,2)", fields[1].Type().LogicalType().String())
+	assert.Equal(t, "DATE", fields[4].Type().LogicalType().String())
+
+	rows := make([]parquet.Row, 2)
+	n, err := parquet.NewReader(f).ReadRows(rows)
+	if err != nil {
+		require.Equal(t, 2, n, err)
+	}
+	first, second := rows[0], rows[1]
+	assert.Equal(t, int32(1), first[0].Int32())
+	assert.Equal(t, int64(-1250), first[1].Int64())
+	assert.Equal(t, twosComplement(big.NewInt(-1), 16), first[2].ByteArray())
+	assert.Equal(t, placed.UnixMicro(), first[3].Int64())
+	assert.Equal(t, int32(19783), first[4].Int32())
+	assert.Equal(t, "hello", string(first[5].ByteArray()))
+	assert.True(t, first[6].Boolean())
+	for i := 1; i < 6; i++ {
+		assert.True(t, second[i].IsNull(), "column %d", i)
+	}
+	assert.False(t, second[6].Boolean())
+}
+
+func TestParquetFileWriterRejectsNullInRequiredColumn(t *testing.T) {
+	w := newParquetFileWriter(&bytes.Buffer{}, []parquetColumnSpec{{name: "id", dbType: "INT"}})
+	err := w.write([]interface{}{nil})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column "id" is declared NOT NULL`)
+}
+
+func TestParquetMaskedColumnIsString(t *testing.T) {
+	column := parquetColumnOf(parquetColumnSpec{name: "ssn", dbType: "INT", masked: true})
+	assert.Equal(t, parquet.ByteArray, column.Type().Kind())
+	value, err := column.value(maskText)
+	require.NoError(t, err)
+	assert.Equal(t, maskText, string(value.ByteArray()))
+}
+
+func TestUnscaledDecimal(t *testing.T) {
+	for text, want := range map[string]int64{"12.5": 1250, "-0.01": -1, "7": 700} {
+		got, err := unscaledDecimal([]byte(text), 2)
+		require.NoError(t, err, text)
+		assert.Equal(t, want, got.Int64(), text)
+	}
+	_, err := unscaledDecimal([]byte("1.234"), 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// checkPermissionsEnabled reports whether MSSQL_CHECK_PERMISSIONS asks for
+// mutating statements to be checked with HAS_PERMS_BY_NAME before they run.
+func checkPermissionsEnabled() bool {
+	return boolEnv("MSSQL_CHECK_PERMISSIONS")
+}
+
+// requiredPermission is an object permission a statement needs.
+type requiredPermission struct {
+	permission string
+	// object is the target as written in the query, quoted part by part.
+	object string
+}
+
+// mutationPermissions maps the statements the permission check understands
+// to the object permission each needs on its target. TRUNCATE TABLE needs
+// ALTER rather than DELETE.
+var mutationPermissions = map[string]string{
+	"insert":   "INSERT",
+	"update":   "UPDATE",
+	"delete":   "DELETE",
+	"truncate": "ALTER",
+}
+
+// requiredPermissions finds the INSERT, UPDATE, DELETE, MERGE and TRUNCATE
+// TABLE statements in query and the permission each needs on its target
+// table. Temp tables and table variables are skipped. A MERGE needs the
+// permission for each action its WHEN clauses take.
+func requiredPermissions(query string) ([]requiredPermission, error) {
+	tokens := sqlTokensWithSymbols(query)
+	var required []requiredPermission
+	seen := make(map[requiredPermission]bool)
+	add := func(permission string, parts []string) error {
+		quoted := make([]string, len(parts))
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			var err error
+			if quoted[i], err = quoteIdentifier(part); err != nil {
+				return err
+			}
+		}
+		p := requiredPermission{permission: permission, object: strings.Join(quoted, ".")}
+		if !seen[p] {
+			seen[p] = true
+			required = append(required, p)
+		}
+		return nil
+	}
+
+	for i, keyword := range tokens {
+		var permissions []string
+		switch {
+		case keyword == "merge":
+			permissions = mergePermissions(tokens[i+1:])
+		case mutationPermissions[keyword] == "":
+			continue
+		case i > 0 && tokens[i-1] == "then":
+			// Actions inside a MERGE are covered by the MERGE itself.
+			continue
+		default:
+			permissions = []string{mutationPermissions[keyword]}
+		}
+
+		parts, ok := mutationTarget(tokens, i+1)
+		if !ok {
+			continue
+		}
+		for _, permission := range permissions {
+			if err := add(permission, parts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return required, nil
+}
+
+// mutationTarget returns the name parts of the table a mutating statement
+// acts on, reading from tokens[j] past TOP (n), INTO, FROM and TABLE. It
+// reports false for temp tables, table variables and anything that is not a
+// name.
+func mutationTarget(tokens []string, j int) ([]string, bool) {
+	for j < len(tokens) {
+		if tokens[j] == "top" && j+1 < len(tokens) && tokens[j+1] == "(" {
+			j = skipParens(tokens, j+1)
+		} else if tokens[j] == "into" || tokens[j] == "from" || tokens[j] == "table" {
+			j++
+		} else {
+			break
+		}
+	}
+	if j >= len(tokens) || !isIdentifierToken(tokens[j]) || aliasStopWords[tokens[j]] ||
+		strings.HasPrefix(tokens[j], "@") || strings.HasPrefix(tokens[j], "#") {
+		return nil, false
+	}
+
+	parts := []string{identifierText(tokens[j])}
+	for j+2 < len(tokens) && tokens[j+1] == "." && isIdentifierToken(tokens[j+2]) {
+		parts = append(parts, identifierText(tokens[j+2]))
+		j += 2
+	}
+	return parts, true
+}
+
+// mergePermissions returns the permissions a MERGE needs from the actions
+// after THEN in the statement that starts with tokens, up to the next
+// semicolon.
+func mergePermissions(tokens []string) []string {
+	var permissions []string
+	seen := make(map[string]bool)
+	for i := 1; i < len(tokens) && tokens[i] != ";"; i++ {
+		permission := mutationPermissions[tokens[i]]
+		if tokens[i-1] == "then" && permission != "" && !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// checkPermissions asks SQL Server, with HAS_PERMS_BY_NAME, whether the
+// current user holds every permission query's mutating statements need, so a
+// missing grant is reported by name instead of as error 229 part way through
+// the batch. Targets the server does not recognize, such as aliases or tables
+// the batch itself creates, are left for the statement to report.
+func checkPermissions(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string) error {
+	required, err := requiredPermissions(query)
+	if err != nil || len(required) == 0 {
+		return err
+	}
+
+	opts.maxRows = 0
+	for _, p := range required {
+		result, err := runQuery(ctx, dm, opts, "SELECT HAS_PERMS_BY_NAME(@p1, 'OBJECT', @p2)", p.object, p.permission)
+		if err != nil {
+			return fmt.Errorf("permission check failed: %v", err)
+		}
+		if len(result.rows) > 0 && result.rows[0][0] != nil && formatValue(result.rows[0][0]) == "0" {
+			return fmt.Errorf("permission denied: you lack %s permission on %s", p.permission, p.object)
+		}
+	}
+	return nil
+}
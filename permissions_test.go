@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredPermissions(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []requiredPermission
+	}{
+		{"select", "SELECT * FROM dbo.orders", nil},
+		{"insert", "INSERT INTO dbo.orders (id) VALUES (1)", []requiredPermission{{"INSERT", "[dbo].[orders]"}}},
+		{"insert without into", "INSERT orders VALUES (1)", []requiredPermission{{"INSERT", "[orders]"}}},
+		{"update top", "UPDATE TOP (10) [Sales].[Order Lines] SET qty = 0", []requiredPermission{{"UPDATE", "[sales].[order lines]"}}},
+		{"delete", "DELETE FROM dbo.orders WHERE id = 1", []requiredPermission{{"DELETE", "[dbo].[orders]"}}},
+		{"truncate", "TRUNCATE TABLE dbo.orders", []requiredPermission{{"ALTER", "[dbo].[orders]"}}},
+		{
+			"merge",
+			"MERGE dbo.orders AS t USING staging s ON t.id = s.id WHEN MATCHED THEN UPDATE SET t.qty = s.qty WHEN NOT MATCHED THEN INSERT (id) VALUES (s.id);",
+			[]requiredPermission{{"UPDATE", "[dbo].[orders]"}, {"INSERT", "[dbo].[orders]"}},
+		},
+		{"temp table", "INSERT INTO #staging VALUES (1); DELETE FROM @rows", nil},
+		{
+			"batch",
+			"DELETE FROM dbo.a; DELETE FROM dbo.a; UPDATE dbo.b SET x = 1",
+			[]requiredPermission{{"DELETE", "[dbo].[a]"}, {"UPDATE", "[dbo].[b]"}},
+		},
+		{"string literal", "SELECT 'DELETE FROM dbo.orders'", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requiredPermissions(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
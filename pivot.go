@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxPivotColumns bounds how many distinct column-key values pivot_query turns
+// into columns, so pivoting on a near-unique column fails instead of
+// producing an unreadable table.
+const maxPivotColumns = 100
+
+// pivotSpec names the columns of the base result a pivot uses: each distinct
+// rowKey value becomes a row, each distinct columnKey value a column, and
+// value fills the cells.
+type pivotSpec struct {
+	rowKey    string
+	columnKey string
+	value     string
+}
+
+// pivotColumnIndex finds name among columns, case-insensitively.
+func pivotColumnIndex(columns []string, name, role string) (int, error) {
+	for i, column := range columns {
+		if strings.EqualFold(column, name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%s column '%s' is not in the query result, which returns (%s)", role, name, strings.Join(columns, ", "))
+}
+
+// pivotResult turns base into a cross-tab. Rows and columns appear in the
+// order their keys are first seen, so an ORDER BY in the base query orders
+// both. A NULL column key becomes a column named NULL, and cells with no
+// base row are NULL. Two base rows for the same cell are an error rather
+// than being aggregated; the base query should GROUP BY instead.
+func pivotResult(base *queryResult, spec pivotSpec) (*queryResult, error) {
+	rowIndex, err := pivotColumnIndex(base.columns, spec.rowKey, "row key")
+	if err != nil {
+		return nil, err
+	}
+	columnIndex, err := pivotColumnIndex(base.columns, spec.columnKey, "column key")
+	if err != nil {
+		return nil, err
+	}
+	valueIndex, err := pivotColumnIndex(base.columns, spec.value, "value")
+	if err != nil {
+		return nil, err
+	}
+
+	pivoted := &queryResult{
+		set:     1,
+		columns: []string{base.columns[rowIndex]},
+		types:   []string{base.types[rowIndex]},
+		maxRows: base.maxRows,
+	}
+	rowPositions := make(map[string]int)
+	columnPositions := make(map[string]int)
+	filled := make(map[[2]int]bool)
+	for _, row := range base.rows {
+		columnKey, err := diffRowKey(row[columnIndex : columnIndex+1])
+		if err != nil {
+			return nil, err
+		}
+		column, ok := columnPositions[columnKey]
+		if !ok {
+			if len(columnPositions) == maxPivotColumns {
+				return nil, fmt.Errorf("column key '%s' has more than %d distinct values; filter or group the query so fewer columns are produced", spec.columnKey, maxPivotColumns)
+			}
+			column = len(pivoted.columns)
+			columnPositions[columnKey] = column
+			name := defaultNullText
+			if row[columnIndex] != nil {
+				name = formatValue(row[columnIndex])
+			}
+			pivoted.columns = append(pivoted.columns, name)
+			pivoted.types = append(pivoted.types, base.types[valueIndex])
+		}
+
+		rowKey, err := diffRowKey(row[rowIndex : rowIndex+1])
+		if err != nil {
+			return nil, err
+		}
+		position, ok := rowPositions[rowKey]
+		if !ok {
+			position = len(pivoted.rows)
+			rowPositions[rowKey] = position
+			pivoted.rows = append(pivoted.rows, []interface{}{row[rowIndex]})
+		}
+
+		cells := pivoted.rows[position]
+		for len(cells) <= column {
+			cells = append(cells, nil)
+		}
+		if filled[[2]int{position, column}] {
+			return nil, fmt.Errorf("more than one row has %s %s and %s %s; aggregate the values with GROUP BY first", spec.rowKey, formatValue(row[rowIndex]), spec.columnKey, pivoted.columns[column])
+		}
+		cells[column] = row[valueIndex]
+		filled[[2]int{position, column}] = true
+		pivoted.rows[position] = cells
+	}
+	for i, cells := range pivoted.rows {
+		for len(cells) < len(pivoted.columns) {
+			cells = append(cells, nil)
+		}
+		pivoted.rows[i] = cells
+	}
+	return pivoted, nil
+}
+
+// pivotQuery runs query and returns its result pivoted by spec in format. The
+// base query reads at most opts.maxRows rows; a note says when that limit cut
+// the input short, since the pivot would then be incomplete.
+func pivotQuery(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, spec pivotSpec, format string) (string, error) {
+	if err := validateFormat(format); err != nil {
+		return "", err
+	}
+
+	base, err := runQuery(ctx, dm, opts, query)
+	if err != nil {
+		return "", err
+	}
+	maskResults([]*queryResult{base})
+	pivoted, err := pivotResult(base, spec)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := formatResult(pivoted, format, "Query returned no rows to pivot.", opts)
+	if err != nil {
+		return "", err
+	}
+	if base.truncated {
+		output += fmt.Sprintf("\n... (only the first %d rows of the query were pivoted; pass max_rows to include more)", base.maxRows)
+	}
+	return output, nil
+}
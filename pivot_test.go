@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPivotResult(t *testing.T) {
+	base := &queryResult{
+		columns: []string{"region", "quarter", "total"},
+		types:   []string{"NVARCHAR", "NVARCHAR", "INT"},
+		rows: [][]interface{}{
+			{"east", "Q1", int64(10)},
+			{"east", "Q2", int64(20)},
+			{"west", "Q2", int64(5)},
+			{"west", nil, int64(1)},
+		},
+		maxRows: 1000,
+	}
+
+	pivoted, err := pivotResult(base, pivotSpec{rowKey: "Region", columnKey: "quarter", value: "TOTAL"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"region", "Q1", "Q2", "NULL"}, pivoted.columns)
+	assert.Equal(t, []string{"NVARCHAR", "INT", "INT", "INT"}, pivoted.types)
+	assert.Equal(t, [][]interface{}{
+		{"east", int64(10), int64(20), nil},
+		{"west", nil, int64(5), int64(1)},
+	}, pivoted.rows)
+
+	_, err = pivotResult(base, pivotSpec{rowKey: "region", columnKey: "month", value: "total"})
+	assert.EqualError(t, err, "column key column 'month' is not in the query result, which returns (region, quarter, total)")
+
+	base.rows = append(base.rows, []interface{}{"east", "Q1", int64(3)})
+	_, err = pivotResult(base, pivotSpec{rowKey: "region", columnKey: "quarter", value: "total"})
+	assert.EqualError(t, err, "more than one row has region east and quarter Q1; aggregate the values with GROUP BY first")
+}
+
+func TestPivotResultColumnLimit(t *testing.T) {
+	base := &queryResult{columns: []string{"k", "c", "v"}, types: []string{"INT", "INT", "INT"}}
+	for i := 0; i <= maxPivotColumns; i++ {
+		base.rows = append(base.rows, []interface{}{int64(1), int64(i), int64(i)})
+	}
+	_, err := pivotResult(base, pivotSpec{rowKey: "k", columnKey: "c", value: "v"})
+	assert.EqualError(t, err, fmt.Sprintf("column key 'c' has more than %d distinct values; filter or group the query so fewer columns are produced", maxPivotColumns))
+
+	base.rows = base.rows[:maxPivotColumns]
+	pivoted, err := pivotResult(base, pivotSpec{rowKey: "k", columnKey: "c", value: "v"})
+	require.NoError(t, err)
+	assert.Len(t, pivoted.columns, maxPivotColumns+1)
+}
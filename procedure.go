@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// procedureParam is one declared parameter of a stored procedure.
+type procedureParam struct {
+	// name includes the leading @.
+	name     string
+	typeDecl string
+	output   bool
+}
+
+// procedureRef is a stored procedure resolved against sys.procedures.
+type procedureRef struct {
+	schema string
+	name   string
+	params []procedureParam
+}
+
+func (p *procedureRef) quotedName() (string, error) {
+	schema, err := quoteIdentifier(p.schema)
+	if err != nil {
+		return "", err
+	}
+	name, err := quoteIdentifier(p.name)
+	if err != nil {
+		return "", err
+	}
+	return schema + "." + name, nil
+}
+
+// resolveProcedure looks procedure up in sys.procedures, along with its
+// parameters in declaration order. Unqualified names are looked up in
+// MSSQL_DEFAULT_SCHEMA when it is set, and in every schema otherwise.
+func resolveProcedure(ctx context.Context, dm *DatabaseManager, connection, procedure string) (*procedureRef, error) {
+	schema, name := splitTableName(strings.TrimSpace(procedure))
+	if name == "" {
+		return nil, fmt.Errorf("procedure name is empty")
+	}
+	if schema == "" {
+		schema = defaultSchema()
+	}
+
+	query := `SELECT SCHEMA_NAME(pr.schema_id), pr.name, p.name,
+       CASE WHEN t.is_user_defined = 1 THEN SCHEMA_NAME(t.schema_id) END, t.name,
+       CASE WHEN t.name IN ('nchar', 'nvarchar') AND p.max_length > 0 THEN p.max_length / 2 ELSE p.max_length END,
+       p.precision, p.scale, p.is_output
+FROM sys.procedures pr
+LEFT JOIN sys.parameters p ON p.object_id = pr.object_id AND p.parameter_id > 0
+LEFT JOIN sys.types t ON t.user_type_id = p.user_type_id
+WHERE pr.name = @name`
+	args := []interface{}{sql.Named("name", name)}
+	if schema != "" {
+		query += " AND SCHEMA_NAME(pr.schema_id) = @schema"
+		args = append(args, sql.Named("schema", schema))
+	}
+	query += "\nORDER BY SCHEMA_NAME(pr.schema_id), p.parameter_id"
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection, maxRows: schemaMaxRows}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.rows) == 0 {
+		return nil, fmt.Errorf("procedure '%s' not found", procedure)
+	}
+
+	ref := &procedureRef{schema: formatValue(result.rows[0][0]), name: formatValue(result.rows[0][1])}
+	for _, row := range result.rows {
+		if formatValue(row[0]) != ref.schema {
+			return nil, fmt.Errorf("procedure name '%s' is ambiguous; qualify it with a schema, e.g. %s.%s", procedure, ref.schema, ref.name)
+		}
+		if row[2] == nil {
+			// A procedure without parameters still yields one row.
+			continue
+		}
+
+		typeDecl := columnTypeName(formatValue(row[4]), row[5], row[6], row[7])
+		if row[3] != nil {
+			// Alias types are referenced by name, which carries any length.
+			typeSchema, err := quoteIdentifier(formatValue(row[3]))
+			if err != nil {
+				return nil, err
+			}
+			typeName, err := quoteIdentifier(formatValue(row[4]))
+			if err != nil {
+				return nil, err
+			}
+			typeDecl = typeSchema + "." + typeName
+		}
+		ref.params = append(ref.params, procedureParam{
+			name:     formatValue(row[2]),
+			typeDecl: typeDecl,
+			output:   row[8] == true,
+		})
+	}
+	return ref, nil
+}
+
+// procedureCall is the batch that runs a procedure, with its arguments.
+type procedureCall struct {
+	query string
+	args  []interface{}
+	// outputs names the OUTPUT parameters returned by the batch's final
+	// SELECT, without the leading @.
+	outputs []string
+}
+
+// buildProcedureCall builds an EXEC of ref binding values by parameter name.
+// Input values are passed as @pN arguments. Each OUTPUT parameter is backed
+// by a local variable declared with the parameter's own type, seeded with the
+// supplied value if any, and read back by a SELECT after the call. Parameters
+// that are not supplied and are not OUTPUT are left to their defaults.
+func buildProcedureCall(ref *procedureRef, values map[string]interface{}) (*procedureCall, error) {
+	quotedName, err := ref.quotedName()
+	if err != nil {
+		return nil, err
+	}
+
+	// Names match case-insensitively, as they do in T-SQL.
+	supplied := make(map[string]string, len(values))
+	for name := range values {
+		supplied[strings.ToLower(strings.TrimPrefix(name, "@"))] = name
+	}
+
+	call := &procedureCall{}
+	var declares, assignments, selects []string
+	for _, param := range ref.params {
+		key := strings.ToLower(strings.TrimPrefix(param.name, "@"))
+		suppliedName, ok := supplied[key]
+		delete(supplied, key)
+		if !ok && !param.output {
+			continue
+		}
+
+		placeholder := ""
+		if ok {
+			arg, err := coerceParam(values[suppliedName])
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %v", param.name, err)
+			}
+			call.args = append(call.args, sql.Named(fmt.Sprintf("p%d", len(call.args)+1), arg))
+			placeholder = fmt.Sprintf("@p%d", len(call.args))
+		}
+
+		if !param.output {
+			assignments = append(assignments, param.name+" = "+placeholder)
+			continue
+		}
+
+		variable := fmt.Sprintf("@out%d", len(call.outputs)+1)
+		declare := "DECLARE " + variable + " " + param.typeDecl
+		if placeholder != "" {
+			declare += " = " + placeholder
+		}
+		declares = append(declares, declare+";")
+		assignments = append(assignments, param.name+" = "+variable+" OUTPUT")
+
+		column, err := quoteIdentifier(strings.TrimPrefix(param.name, "@"))
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, variable+" AS "+column)
+		call.outputs = append(call.outputs, strings.TrimPrefix(param.name, "@"))
+	}
+
+	if len(supplied) > 0 {
+		unknown := make([]string, 0, len(supplied))
+		for _, name := range supplied {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		declared := make([]string, len(ref.params))
+		for i, param := range ref.params {
+			declared[i] = param.name
+		}
+		if len(declared) == 0 {
+			declared = []string{"none"}
+		}
+		return nil, fmt.Errorf("%s.%s has no parameter(s) %s (parameters: %s)", ref.schema, ref.name,
+			strings.Join(unknown, ", "), strings.Join(declared, ", "))
+	}
+
+	var query strings.Builder
+	for _, declare := range declares {
+		query.WriteString(declare)
+		query.WriteString("\n")
+	}
+	query.WriteString("EXEC " + quotedName)
+	if len(assignments) > 0 {
+		query.WriteString(" " + strings.Join(assignments, ", "))
+	}
+	query.WriteString(";")
+	if len(selects) > 0 {
+		query.WriteString("\nSELECT " + strings.Join(selects, ", ") + ";")
+	}
+	call.query = query.String()
+	return call, nil
+}
+
+// executeProcedure runs a stored procedure with named parameter values and
+// renders its result sets followed by the values of its OUTPUT parameters.
+func executeProcedure(ctx context.Context, dm *DatabaseManager, opts queryOptions, procedure string, values map[string]interface{}, format string) (string, error) {
+	if err := validateFormat(format); err != nil {
+		return "", err
+	}
+
+	ref, err := resolveProcedure(ctx, dm, opts.connection, procedure)
+	if err != nil {
+		return "", err
+	}
+	call, err := buildProcedureCall(ref, values)
+	if err != nil {
+		return "", err
+	}
+	if err := checkQuery(call.query); err != nil {
+		return "", err
+	}
+
+	results, err := runBatch(ctx, dm, opts, call.query, call.args...)
+	if err != nil {
+		return "", err
+	}
+	maskResults(results)
+
+	// Statements that return no rowset show up as sets without columns.
+	var sets []*queryResult
+	for _, result := range results {
+		if len(result.columns) > 0 {
+			sets = append(sets, result)
+		}
+	}
+	last := results[len(results)-1]
+	note := last.truncationNote()
+
+	// The output SELECT only runs if the batch was read to the end.
+	var outputs *queryResult
+	if len(call.outputs) > 0 && note == "" && len(sets) > 0 {
+		outputs = sets[len(sets)-1]
+		sets = sets[:len(sets)-1]
+	}
+
+	if format == formatJSON {
+		var output strings.Builder
+		output.WriteString(`{"result_sets":[`)
+		for i, set := range sets {
+			if i > 0 {
+				output.WriteString(",")
+			}
+			rows, err := formatJSONRows(set.columns, set.rows)
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(rows)
+		}
+		output.WriteString("]")
+		if outputs != nil && len(outputs.rows) > 0 {
+			keys, err := jsonKeys(outputs.columns)
+			if err != nil {
+				return "", err
+			}
+			object, err := jsonObject(outputs.columns, keys, outputs.rows[0])
+			if err != nil {
+				return "", err
+			}
+			output.WriteString(`,"output_params":`)
+			output.Write(object)
+		}
+		output.WriteString("}")
+		return output.String() + note, nil
+	}
+
+	var output strings.Builder
+	for i, set := range sets {
+		// NDJSON lines stand alone, so sets and output parameters are not
+		// given headers.
+		if i > 0 && format != formatNDJSON {
+			output.WriteString("\n")
+			output.WriteString(resultSetHeader(i + 1))
+		}
+		formatted, err := formatResult(set, format, "(no rows)\n", opts)
+		if err != nil {
+			return "", err
+		}
+		output.WriteString(formatted)
+	}
+	if outputs != nil {
+		if format != formatNDJSON {
+			if output.Len() > 0 {
+				output.WriteString("\n")
+			}
+			output.WriteString("-- Output parameters --\n")
+		}
+		formatted, err := formatResult(outputs, format, "(no rows)\n", opts)
+		if err != nil {
+			return "", err
+		}
+		output.WriteString(formatted)
+	}
+	if output.Len() == 0 {
+		return "Procedure executed successfully. No rows returned." + note, nil
+	}
+	return output.String() + note, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildProcedureCall(t *testing.T) {
+	ref := &procedureRef{schema: "dbo", name: "place]order", params: []procedureParam{
+		{name: "@customer_id", typeDecl: "int"},
+		{name: "@note", typeDecl: "nvarchar(200)"},
+		{name: "@order_id", typeDecl: "int", output: true},
+		{name: "@total", typeDecl: "decimal(18,2)", output: true},
+	}}
+
+	call, err := buildProcedureCall(ref, map[string]interface{}{"Customer_ID": float64(42), "@total": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, "DECLARE @out1 int;\n"+
+		"DECLARE @out2 decimal(18,2) = @p2;\n"+
+		"EXEC [dbo].[place]]order] @customer_id = @p1, @order_id = @out1 OUTPUT, @total = @out2 OUTPUT;\n"+
+		"SELECT @out1 AS [order_id], @out2 AS [total];", call.query)
+	assert.Equal(t, []interface{}{sql.Named("p1", int64(42)), sql.Named("p2", "0")}, call.args)
+	assert.Equal(t, []string{"order_id", "total"}, call.outputs)
+
+	_, err = buildProcedureCall(ref, map[string]interface{}{"customer": 1, "x; DROP TABLE t": 2})
+	assert.ErrorContains(t, err, "has no parameter(s) customer, x; DROP TABLE t")
+
+	call, err = buildProcedureCall(&procedureRef{schema: "dbo", name: "refresh"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "EXEC [dbo].[refresh];", call.query)
+	assert.Empty(t, call.args)
+}
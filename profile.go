@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// profileFormat returns the output format used when a call does not pass
+// one, from MSSQL_DEFAULT_FORMAT.
+func profileFormat() string {
+	if format := strings.ToLower(strings.TrimSpace(os.Getenv("MSSQL_DEFAULT_FORMAT"))); format != "" {
+		return format
+	}
+	return formatText
+}
+
+// profileMaxRows returns the row cap used when a call does not pass
+// max_rows, from MSSQL_DEFAULT_MAX_ROWS, or zero to fall back to
+// MSSQL_MAX_ROWS.
+func profileMaxRows() int {
+	return intEnv("MSSQL_DEFAULT_MAX_ROWS", 0, 1)
+}
+
+// profileNullText returns how NULL is shown when a call does not pass
+// null_text, from MSSQL_DEFAULT_NULL_TEXT.
+func profileNullText() string {
+	if nullText, ok := os.LookupEnv("MSSQL_DEFAULT_NULL_TEXT"); ok {
+		return nullText
+	}
+	return defaultNullText
+}
+
+// requestOutputOptions reads the format, max_rows, null_text, separator,
+// include_types, include_stats and include_waits arguments of a query tool
+// call. Each argument the call leaves out takes its value from the matching
+// MSSQL_DEFAULT_* variable, where there is one, and then from the built-in
+// default.
+func requestOutputOptions(request mcp.CallToolRequest) (string, queryOptions) {
+	format := request.GetString("format", profileFormat())
+	opts := queryOptions{
+		maxRows:      request.GetInt("max_rows", profileMaxRows()),
+		nullText:     request.GetString("null_text", profileNullText()),
+		separator:    request.GetString("separator", ""),
+		includeTypes: request.GetBool("include_types", boolEnv("MSSQL_DEFAULT_INCLUDE_TYPES")),
+		includeStats: request.GetBool("include_stats", boolEnv("MSSQL_DEFAULT_INCLUDE_STATS")),
+		includeWaits: request.GetBool("include_waits", false),
+	}
+	return format, opts
+}
+
+// checkProfileOnStart rejects an MSSQL_DEFAULT_FORMAT that no tool could
+// honor, so the mistake shows up at startup rather than on every call.
+func checkProfileOnStart() error {
+	if err := validateFormat(profileFormat()); err != nil {
+		return fmt.Errorf("MSSQL_DEFAULT_FORMAT: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func callWithArguments(arguments map[string]interface{}) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	return request
+}
+
+func TestRequestOutputOptions(t *testing.T) {
+	for _, name := range []string{"MSSQL_DEFAULT_FORMAT", "MSSQL_DEFAULT_MAX_ROWS", "MSSQL_DEFAULT_INCLUDE_TYPES", "MSSQL_DEFAULT_INCLUDE_STATS"} {
+		t.Setenv(name, "")
+	}
+
+	format, opts := requestOutputOptions(callWithArguments(nil))
+	assert.Equal(t, formatText, format)
+	assert.Equal(t, 0, opts.maxRows)
+	assert.Equal(t, defaultNullText, opts.nullText)
+	assert.False(t, opts.includeTypes)
+	assert.False(t, opts.includeStats)
+
+	t.Setenv("MSSQL_DEFAULT_FORMAT", "JSON")
+	t.Setenv("MSSQL_DEFAULT_MAX_ROWS", "500")
+	t.Setenv("MSSQL_DEFAULT_NULL_TEXT", "")
+	t.Setenv("MSSQL_DEFAULT_INCLUDE_STATS", "true")
+
+	// Omitted arguments come from the profile.
+	format, opts = requestOutputOptions(callWithArguments(map[string]interface{}{}))
+	assert.Equal(t, formatJSON, format)
+	assert.Equal(t, 500, opts.maxRows)
+	assert.Equal(t, "", opts.nullText)
+	assert.True(t, opts.includeStats)
+
+	// Arguments passed on the call always win.
+	format, opts = requestOutputOptions(callWithArguments(map[string]interface{}{
+		"format":        "csv",
+		"max_rows":      float64(10),
+		"null_text":     "-",
+		"include_stats": false,
+	}))
+	assert.Equal(t, formatCSV, format)
+	assert.Equal(t, 10, opts.maxRows)
+	assert.Equal(t, "-", opts.nullText)
+	assert.False(t, opts.includeStats)
+}
+
+func TestCheckProfileOnStart(t *testing.T) {
+	t.Setenv("MSSQL_DEFAULT_FORMAT", "markdown")
+	assert.NoError(t, checkProfileOnStart())
+
+	t.Setenv("MSSQL_DEFAULT_FORMAT", "xml")
+	assert.ErrorContains(t, checkProfileOnStart(), "MSSQL_DEFAULT_FORMAT: unsupported format 'xml'")
+}
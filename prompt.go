@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const safeQueryInstructions = `You are writing T-SQL for Microsoft SQL Server through the execute_sql tool.
+
+Rules:
+- Write a single read-only SELECT statement. Never use INSERT, UPDATE, DELETE, MERGE, DROP, ALTER, TRUNCATE, EXEC or any other statement that changes data or schema.
+- Do not interpolate literal values taken from the question into the SQL text. Use @p1, @p2, ... placeholders and pass the values in order as the params argument of execute_sql.
+- Only reference the tables and columns listed below, using schema-qualified names.
+- Select only the columns needed to answer the question and limit large results with TOP.
+
+Available schema:
+%s`
+
+// safeQueryMessages builds the write_safe_query prompt from the question and
+// a schema snapshot.
+func safeQueryMessages(question, schema string) []mcp.PromptMessage {
+	return []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(safeQueryInstructions, schema))),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Question: "+question)),
+	}
+}
+
+// getSafeQueryPrompt renders write_safe_query with the current schema. The
+// prompt is still returned when the schema cannot be read so the client sees
+// why the table list is missing.
+func getSafeQueryPrompt(ctx context.Context, schemas *schemaCache, question string) *mcp.GetPromptResult {
+	schema, err := schemas.get(ctx)
+	if err != nil {
+		schema = fmt.Sprintf("(schema unavailable: %v)", err)
+	}
+
+	return mcp.NewGetPromptResult(
+		"Generate a read-only parameterized query for the question",
+		safeQueryMessages(question, schema),
+	)
+}
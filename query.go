@@ -0,0 +1,597 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// queryOptions carries the per-call settings shared by every query path.
+type queryOptions struct {
+	// connection names the target database; empty selects the default.
+	connection string
+	// maxRows caps the rows read per result set; zero or less uses
+	// MSSQL_MAX_ROWS.
+	maxRows int
+	// database switches the query to another catalog on the same instance;
+	// empty keeps the connection's own database.
+	database string
+	// tx runs the query inside an open transaction instead of on the
+	// connection pool; connection and database are then ignored.
+	tx *transaction
+	// conn runs the query on a reserved connection instead of the pool, so
+	// session state carries over between queries; database is ignored.
+	conn *sql.Conn
+	// includeTypes annotates the output with each column's SQL Server type.
+	includeTypes bool
+	// nullText is shown for NULL values in text output.
+	nullText string
+	// separator goes between the columns of text output; empty means
+	// MSSQL_COLUMN_SEPARATOR.
+	separator string
+	// partialOnTimeout returns the rows read so far, marked as incomplete,
+	// when the query timeout expires while rows are being read.
+	partialOnTimeout bool
+	// includeStats adds the row count and elapsed time to the output of
+	// executeQuery: a header line in text, a metadata object in JSON.
+	includeStats bool
+	// includeWaits adds the CPU time, I/O and waits the query caused on its
+	// session to the output of executeQuery, in the same places as
+	// includeStats.
+	includeWaits bool
+	// timeout replaces MSSQL_QUERY_TIMEOUT_SECONDS for this query when
+	// positive.
+	timeout time.Duration
+	// maxColumns keeps only the first maxColumns columns of each result set
+	// in the output of executeQuery and streamQuery when positive.
+	maxColumns int
+	// rawValues hands scanRows callers values exactly as the driver scanned
+	// them, for writers such as Parquet that map driver types themselves.
+	rawValues bool
+}
+
+// queryResult holds the materialized output of one result set.
+type queryResult struct {
+	// set is the 1-based position of this result set within the batch.
+	set     int
+	columns []string
+	// types holds the SQL Server type name of each column, e.g. NVARCHAR.
+	types []string
+	// columnTypes is the driver's full column metadata, including
+	// nullability and decimal precision, as scanRows found it.
+	columnTypes []*sql.ColumnType
+	rows        [][]interface{}
+	// truncated is set when more rows were available than maxRows allowed.
+	truncated bool
+	maxRows   int
+	// timedOut is set when the query timeout cut reading short and the rows
+	// gathered so far were kept.
+	timedOut bool
+	timeout  time.Duration
+}
+
+// truncationNote returns the trailer appended to output when the row cap was
+// hit or the query timed out part way through, or an empty string otherwise.
+func (r *queryResult) truncationNote() string {
+	switch {
+	case r.timedOut:
+		return fmt.Sprintf("\n... (INCOMPLETE RESULT: the query timed out after %v; only the rows read before the timeout are shown)", r.timeout)
+	case r.truncated:
+		return fmt.Sprintf("\n... (truncated at %d rows)", r.maxRows)
+	}
+	return ""
+}
+
+// typedColumns returns the column names with their type appended, e.g.
+// "id (INT)". Columns whose type the driver did not report keep the bare name.
+func (r *queryResult) typedColumns() []string {
+	columns := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		columns[i] = col
+		if i < len(r.types) && r.types[i] != "" {
+			columns[i] = fmt.Sprintf("%s (%s)", col, r.types[i])
+		}
+	}
+	return columns
+}
+
+// resultSetHeader separates the output of consecutive result sets.
+func resultSetHeader(set int) string {
+	return fmt.Sprintf("-- Result set %d --\n", set)
+}
+
+// wrapQueryError explains a query failure, distinguishing deadline expiry and
+// explicit cancellation from ordinary database errors. SQL Server errors keep
+// their number, severity and state in the message and remain reachable with
+// errors.As.
+func wrapQueryError(ctx context.Context, timeout time.Duration, action string, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("query cancelled after exceeding the %v timeout: %w", timeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("query was cancelled: %w", err)
+	}
+	return fmt.Errorf("%s: %w%s", action, err, sqlErrorDetails(err))
+}
+
+// scanRows executes query with opts.timeout or the configured timeout and passes each row, as
+// normalized driver values, to handle along with the result set it belongs to. Every
+// result set in the batch is visited in order. At most opts.maxRows rows are
+// read from a set; hitting the cap stops reading the batch altogether. The
+// returned results carry column names and truncation state but no rows. When
+// opts.database is set the query runs on a dedicated connection switched to
+// that database; with opts.tx or opts.conn it runs inside that transaction or
+// on that connection.
+func scanRows(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args []interface{}, handle func(result *queryResult, values []interface{}) error) ([]*queryResult, error) {
+	maxRows := opts.maxRows
+	if maxRows <= 0 {
+		maxRows = maxRowsLimit()
+	}
+
+	timeout := opts.timeout
+	if timeout <= 0 {
+		timeout = queryTimeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var querier interface {
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	}
+	if opts.tx != nil {
+		opts.tx.mu.Lock()
+		defer opts.tx.mu.Unlock()
+		querier = opts.tx.tx
+	} else if opts.conn != nil {
+		querier = opts.conn
+	} else {
+		db, err := dm.getConnection(opts.connection)
+		if err != nil {
+			return nil, fmt.Errorf("database connection unavailable: %v", err)
+		}
+		querier = db
+		if opts.database != "" {
+			conn, release, err := useDatabase(ctx, db, opts.database)
+			if err != nil {
+				return nil, wrapQueryError(ctx, timeout, "database switch failed", err)
+			}
+			defer release()
+			querier = conn
+		}
+	}
+
+	rows, err := querier.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, wrapQueryError(ctx, timeout, "query execution failed", err)
+	}
+	defer rows.Close()
+
+	var results []*queryResult
+	for {
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get column information: %v", err)
+		}
+
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get column types: %v", err)
+		}
+		types := make([]string, len(columnTypes))
+		for i, ct := range columnTypes {
+			types[i] = ct.DatabaseTypeName()
+		}
+
+		result := &queryResult{set: len(results) + 1, columns: columns, types: types, columnTypes: columnTypes, maxRows: maxRows}
+		results = append(results, result)
+
+		count := 0
+		for rows.Next() {
+			if count == maxRows {
+				// Cancel before the deferred Close so the driver abandons the
+				// remaining rows instead of draining them off the wire.
+				result.truncated = true
+				cancel()
+				return results, nil
+			}
+
+			values := make([]interface{}, len(columns))
+			valuePtrs := make([]interface{}, len(columns))
+
+			for i := range values {
+				valuePtrs[i] = &values[i]
+			}
+
+			if err := rows.Scan(valuePtrs...); err != nil {
+				return nil, fmt.Errorf("failed to scan row: %v", err)
+			}
+			if !opts.rawValues {
+				for i := range values {
+					values[i] = normalizeValue(values[i], types[i])
+				}
+			}
+			if err := handle(result, values); err != nil {
+				return nil, err
+			}
+			count++
+		}
+
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		if opts.partialOnTimeout && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// The timeout ends the batch, so the set being read is the
+			// one that carries the note.
+			last := results[len(results)-1]
+			last.timedOut = true
+			last.timeout = timeout
+			return results, nil
+		}
+		return nil, wrapQueryError(ctx, timeout, "error during row iteration", err)
+	}
+
+	return results, nil
+}
+
+// runBatch executes query and materializes every result set in memory.
+func runBatch(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args ...interface{}) ([]*queryResult, error) {
+	return scanRows(ctx, dm, opts, query, args, func(result *queryResult, values []interface{}) error {
+		result.rows = append(result.rows, values)
+		return nil
+	})
+}
+
+// runQuery executes query and returns its first result set, for callers that
+// issue a single SELECT.
+func runQuery(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args ...interface{}) (*queryResult, error) {
+	results, err := runBatch(ctx, dm, opts, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// streamQuery executes query and writes each row as soon as it is scanned, so
+// only the output itself is held in memory. Rows are tab-delimited text, or one
+// JSON object per line when format is NDJSON.
+func streamQuery(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args []interface{}, format string) (string, error) {
+	var output strings.Builder
+	var current *queryResult
+	var keys [][]byte
+	maxWidth := maxCellWidth()
+	cellsTruncated := false
+	rowCount := 0
+	// Past MSSQL_MAX_OUTPUT_BYTES rows are still counted for the note but no
+	// longer written.
+	limit := maxOutputBytes()
+	shown := 0
+	limited := false
+	start := time.Now()
+	masker := newColumnMasker()
+	omittedColumns := 0
+	results, err := scanRows(ctx, dm, opts, query, args, func(result *queryResult, values []interface{}) error {
+		rowCount++
+		if limited {
+			return nil
+		}
+		masker.apply(result, values)
+		if result != current {
+			omittedColumns += result.limitColumns(opts.maxColumns)
+		}
+		values = values[:len(result.columns)]
+
+		var chunk strings.Builder
+		rowCut := false
+		if format == formatNDJSON {
+			if result != current {
+				var err error
+				if keys, err = jsonKeys(result.columns); err != nil {
+					return err
+				}
+				current = result
+			}
+			object, err := jsonObject(result.columns, keys, values)
+			if err != nil {
+				return err
+			}
+			chunk.Write(object)
+			chunk.WriteString("\n")
+		} else {
+			if result != current {
+				if result.set > 1 {
+					if output.Len() > 0 {
+						chunk.WriteString("\n")
+					}
+					chunk.WriteString(resultSetHeader(result.set))
+				}
+				if opts.includeTypes {
+					writeTSVRow(&chunk, result.typedColumns())
+				} else {
+					writeTSVRow(&chunk, result.columns)
+				}
+				current = result
+			}
+			fields := make([]string, len(values))
+			for i, v := range values {
+				var cut bool
+				fields[i], cut = truncateCell(formatCell(v, opts.nullText), maxWidth)
+				rowCut = rowCut || cut
+			}
+			writeTSVRow(&chunk, fields)
+		}
+
+		if limit > 0 && output.Len()+chunk.Len() > limit {
+			limited = true
+			return nil
+		}
+		output.WriteString(chunk.String())
+		cellsTruncated = cellsTruncated || rowCut
+		shown++
+		return nil
+	})
+	dm.history.record(opts.connection, query, start, rowCount, err)
+	if err != nil {
+		return "", err
+	}
+
+	note := results[len(results)-1].truncationNote()
+	if limited {
+		note = outputLimitNote(limit, shown, rowCount)
+	}
+	if omittedColumns > 0 {
+		note = columnLimitNote(opts.maxColumns, omittedColumns) + note
+	}
+
+	if output.Len() == 0 && !limited && format != formatNDJSON {
+		return "Query executed successfully. No rows returned." + note, nil
+	}
+
+	if cellsTruncated {
+		output.WriteString(cellTruncationNote(maxWidth))
+	}
+
+	return output.String() + note, nil
+}
+
+// formatResult renders a single result set in the requested format, without
+// the truncation note. Text and Markdown output for an empty set is replaced by
+// emptyText. With opts.includeTypes, text, CSV and Markdown headers carry each
+// column's type and JSON output becomes an object with a "types" map alongside
+// the "rows" array; NDJSON lines carry no types. opts.nullText only applies to
+// the text and Markdown formats.
+func formatResult(result *queryResult, format, emptyText string, opts queryOptions) (string, error) {
+	header := result.columns
+	if opts.includeTypes {
+		header = result.typedColumns()
+	}
+
+	var output string
+	var err error
+	switch format {
+	case formatJSON:
+		output, err = formatJSONRows(result.columns, result.rows)
+		if err == nil && opts.includeTypes {
+			var types string
+			types, err = formatJSONTypes(result.columns, result.types)
+			output = `{"types":` + types + `,"rows":` + output + `}`
+		}
+	case formatCSV:
+		output, err = formatCSVRows(header, result.rows)
+	case formatNDJSON:
+		output, err = formatNDJSONRows(result.columns, result.rows)
+	case formatMarkdown:
+		if len(result.rows) == 0 {
+			output = emptyText
+		} else {
+			output = formatMarkdownTable(header, result.rows, opts.nullText)
+		}
+	default:
+		if len(result.rows) == 0 {
+			output = emptyText
+		} else {
+			separator := opts.separator
+			if separator == "" {
+				separator = columnSeparator()
+			}
+			output = formatSeparatedTable(header, result.types, result.rows, opts.nullText, separator)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// statsLine summarizes a query for the top of text output, e.g.
+// "-- 42 rows, 13ms --".
+func statsLine(rows int, elapsed time.Duration) string {
+	noun := "rows"
+	if rows == 1 {
+		noun = "row"
+	}
+	return fmt.Sprintf("-- %d %s, %dms --\n", rows, noun, elapsed.Milliseconds())
+}
+
+// withJSONMetadata wraps JSON query output in an object carrying the row count
+// and elapsed time under "metadata". An object, as produced with include_types,
+// gains the key; a single set's array becomes "rows" and an array of sets
+// becomes "result_sets".
+func withJSONMetadata(output string, sets, rows int, elapsed time.Duration) string {
+	return wrapJSONMetadata(output, sets, fmt.Sprintf(`{"rows":%d,"duration_ms":%d}`, rows, elapsed.Milliseconds()))
+}
+
+// wrapJSONMetadata is withJSONMetadata for an already rendered metadata object.
+func wrapJSONMetadata(output string, sets int, metadata string) string {
+	switch {
+	case strings.HasPrefix(output, "{"):
+		return `{"metadata":` + metadata + "," + strings.TrimPrefix(output, "{")
+	case sets > 1:
+		return `{"metadata":` + metadata + `,"result_sets":` + output + "}"
+	}
+	return `{"metadata":` + metadata + `,"rows":` + output + "}"
+}
+
+func executeQuery(ctx context.Context, dm *DatabaseManager, opts queryOptions, query string, args []interface{}, format string) (string, error) {
+	if err := validateFormat(format); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	run := func() ([]*queryResult, error) {
+		return runBatch(ctx, dm, opts, query, args...)
+	}
+	var results []*queryResult
+	var err error
+	var activity *sessionActivity
+	activityNote := ""
+	if opts.includeWaits {
+		// The counters are per session, so the snapshots and the query must
+		// share a connection. That rules out the reconnect retry too.
+		activity, activityNote, err = withSessionActivity(ctx, dm, opts, func(opts queryOptions) error {
+			var err error
+			results, err = runBatch(ctx, dm, opts, query, args...)
+			return err
+		})
+	} else if opts.tx == nil && opts.conn == nil {
+		// A pool whose connections died with a server restart is replaced
+		// and, for a single read-only SELECT, the query retried once. Other
+		// statements may already have run. Transactions and reserved
+		// connections carry session state that a retry could not restore.
+		results, err = withReconnect(isReadOnlyQuery(query), run, func() { dm.discardConnection(opts.connection) })
+	} else {
+		results, err = run()
+	}
+	rows := 0
+	for _, result := range results {
+		rows += len(result.rows)
+	}
+	dm.history.record(opts.connection, query, start, rows, err)
+	if err != nil {
+		return "", err
+	}
+	maskResults(results)
+	omittedColumns := 0
+	for _, result := range results {
+		omittedColumns += result.limitColumns(opts.maxColumns)
+	}
+
+	output, err := formatResults(results, format, opts)
+	if err != nil {
+		return "", err
+	}
+	// Truncation stops the batch, so only the last set can carry a note.
+	note := results[len(results)-1].truncationNote()
+	if limit := maxOutputBytes(); limit > 0 && len(output) > limit {
+		output, note, err = fitOutput(results, format, opts, limit)
+		if err != nil {
+			return "", err
+		}
+	}
+	if omittedColumns > 0 {
+		note = columnLimitNote(opts.maxColumns, omittedColumns) + note
+	}
+	note += activityNote
+
+	if warnSelectStar() && isUnboundedSelectStar(query) {
+		switch format {
+		case formatJSON, formatCSV, formatNDJSON:
+			// Like the truncation note, keep the warning after the data so
+			// the output still parses.
+			note += "\n" + selectStarWarning
+		default:
+			output = selectStarWarning + output
+		}
+	}
+
+	if !opts.includeStats && activity == nil {
+		return output + note, nil
+	}
+	elapsed := time.Since(start)
+	switch format {
+	case formatJSON:
+		var fields []string
+		if opts.includeStats {
+			fields = append(fields, fmt.Sprintf(`"rows":%d,"duration_ms":%d`, rows, elapsed.Milliseconds()))
+		}
+		if activity != nil {
+			fields = append(fields, `"session_stats":`+activity.json())
+		}
+		// Keep the note outside the JSON so it still parses.
+		return wrapJSONMetadata(output, len(results), "{"+strings.Join(fields, ",")+"}") + note, nil
+	case formatCSV, formatNDJSON:
+		// A leading comment line would break CSV and NDJSON parsers.
+		return output + note, nil
+	}
+	header := ""
+	if opts.includeStats {
+		header = statsLine(rows, elapsed)
+	}
+	if activity != nil {
+		header += activity.line()
+	}
+	return header + output + note, nil
+}
+
+// noColumnsText is the whole output of a query whose only result has no
+// columns.
+const noColumnsText = "Statement executed successfully. It returned no columns."
+
+// columnless reports whether result has no columns, as for SET statements and
+// procedures that return no rowset, in a format that would otherwise show an
+// empty header. JSON and NDJSON render such a set as an empty array or no
+// lines, which still parse.
+func columnless(result *queryResult, format string) bool {
+	return len(result.columns) == 0 && format != formatJSON && format != formatNDJSON
+}
+
+// formatResults renders every result set in format, without any truncation
+// note. A lone set is rendered on its own. For several sets, JSON output stays
+// parseable by wrapping each set's array in an outer array, NDJSON sets simply
+// follow one another, and the other formats get a header line before each
+// later set.
+func formatResults(results []*queryResult, format string, opts queryOptions) (string, error) {
+	if len(results) == 1 {
+		if columnless(results[0], format) {
+			return noColumnsText, nil
+		}
+		return formatResult(results[0], format, "Query executed successfully. No rows returned.", opts)
+	}
+
+	var output strings.Builder
+	if format == formatJSON {
+		output.WriteString("[")
+	}
+	for i, result := range results {
+		formatted, err := formatResult(result, format, "(no rows)\n", opts)
+		if err != nil {
+			return "", err
+		}
+		if columnless(result, format) {
+			formatted = "(no columns)\n"
+		}
+		if i > 0 && format != formatNDJSON {
+			if format == formatJSON {
+				output.WriteString(",")
+			} else {
+				output.WriteString("\n")
+				output.WriteString(resultSetHeader(result.set))
+				if format == formatMarkdown {
+					// A table must not run on from the header line.
+					output.WriteString("\n")
+				}
+			}
+		}
+		output.WriteString(formatted)
+	}
+	if format == formatJSON {
+		output.WriteString("]")
+	}
+
+	return output.String(), nil
+}
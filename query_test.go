@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncationNote(t *testing.T) {
+	assert.Equal(t, "", (&queryResult{}).truncationNote())
+	assert.Equal(t, "\n... (truncated at 10 rows)", (&queryResult{truncated: true, maxRows: 10}).truncationNote())
+	assert.Contains(t, (&queryResult{timedOut: true, timeout: 30 * time.Second}).truncationNote(), "INCOMPLETE RESULT: the query timed out after 30s")
+}
+
+func TestWrapQueryErrorCancellation(t *testing.T) {
+	driverErr := errors.New("mssql: canceled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := wrapQueryError(ctx, 30*time.Second, "query failed", driverErr)
+	assert.EqualError(t, err, "query was cancelled: mssql: canceled")
+	assert.ErrorIs(t, err, driverErr)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	err = wrapQueryError(ctx, 30*time.Second, "query failed", driverErr)
+	assert.EqualError(t, err, "query cancelled after exceeding the 30s timeout: mssql: canceled")
+}
+
+func TestStatsLine(t *testing.T) {
+	assert.Equal(t, "-- 42 rows, 13ms --\n", statsLine(42, 13*time.Millisecond+400*time.Microsecond))
+	assert.Equal(t, "-- 1 row, 0ms --\n", statsLine(1, 0))
+}
+
+func TestWithJSONMetadata(t *testing.T) {
+	assert.Equal(t, `{"metadata":{"rows":1,"duration_ms":5},"rows":[{"id":1}]}`,
+		withJSONMetadata(`[{"id":1}]`, 1, 1, 5*time.Millisecond))
+	assert.Equal(t, `{"metadata":{"rows":2,"duration_ms":5},"result_sets":[[{"id":1}],[{"id":2}]]}`,
+		withJSONMetadata(`[[{"id":1}],[{"id":2}]]`, 2, 2, 5*time.Millisecond))
+	assert.Equal(t, `{"metadata":{"rows":0,"duration_ms":5},"types":{"id":"INT"},"rows":[]}`,
+		withJSONMetadata(`{"types":{"id":"INT"},"rows":[]}`, 1, 0, 5*time.Millisecond))
+}
+
+func TestFormatResultsWithoutColumns(t *testing.T) {
+	empty := &queryResult{set: 1}
+	for _, format := range []string{formatText, formatCSV, formatMarkdown} {
+		out, err := formatResults([]*queryResult{empty}, format, queryOptions{})
+		require.NoError(t, err, format)
+		assert.Equal(t, noColumnsText, out, format)
+	}
+
+	out, err := formatResults([]*queryResult{empty}, formatJSON, queryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "[]", out)
+
+	rows := &queryResult{set: 2, columns: []string{"id"}, rows: [][]interface{}{{int64(1)}}}
+	out, err = formatResults([]*queryResult{empty, rows}, formatCSV, queryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "(no columns)\n\n-- Result set 2 --\nid\n1\n", out)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maxRateLimitClients bounds how many client buckets the limiter keeps. When
+// it is reached the bucket idle the longest is dropped.
+const maxRateLimitClients = 10000
+
+// rateLimitPerMinute returns how many tool calls each HTTP client may make per
+// minute, from MSSQL_RATE_LIMIT_PER_MINUTE, or zero when calls are not
+// limited.
+func rateLimitPerMinute() int {
+	return intEnv("MSSQL_RATE_LIMIT_PER_MINUTE", 0, 1)
+}
+
+// tokenBucket holds one client's unspent calls as of updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter is a token bucket per client: each holds up to perMinute calls
+// and refills continuously at perMinute a minute, so a client may burst to
+// the full allowance and then gets calls back as time passes. A bucket that
+// has refilled is no different from a new one, so buckets idle for a minute
+// are forgotten.
+type rateLimiter struct {
+	perMinute int
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow spends one of client's calls. When none is left it reports how long
+// until the next one is available.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= time.Minute {
+		l.sweep(now)
+	}
+
+	capacity := float64(l.perMinute)
+	bucket, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitClients {
+			l.evictOldest()
+		}
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		l.buckets[client] = bucket
+	}
+
+	perSecond := capacity / 60
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep forgets the buckets that have had a minute to refill.
+func (l *rateLimiter) sweep(now time.Time) {
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= time.Minute {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// evictOldest drops the bucket that was used least recently.
+func (l *rateLimiter) evictOldest() {
+	var oldest string
+	var oldestTime time.Time
+	for client, bucket := range l.buckets {
+		if oldest == "" || bucket.updated.Before(oldestTime) {
+			oldest, oldestTime = client, bucket.updated
+		}
+	}
+	delete(l.buckets, oldest)
+}
+
+// clientAddrKey is the context key under which rememberClientAddr stores the
+// caller's IP address.
+type clientAddrKey struct{}
+
+// rememberClientAddr is an HTTP context function that records the source IP of
+// each request for the rate limiter. X-Forwarded-For is not trusted since any
+// client can set it.
+func rememberClientAddr(ctx context.Context, r *http.Request) context.Context {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return context.WithValue(ctx, clientAddrKey{}, host)
+}
+
+// rateLimitClient identifies the caller of a tool call: its source IP when the
+// HTTP transport recorded one, otherwise its MCP session.
+func rateLimitClient(ctx context.Context) string {
+	if addr, ok := ctx.Value(clientAddrKey{}).(string); ok && addr != "" {
+		return "addr:" + addr
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return "session:" + session.SessionID()
+	}
+	return ""
+}
+
+// middleware is tool middleware that refuses calls over the limit with a
+// result saying when to retry.
+func (l *rateLimiter) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if ok, wait := l.allow(rateLimitClient(ctx)); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			return mcp.NewToolResultError(fmt.Sprintf("Rate limit exceeded: at most %d tool calls per minute are allowed; try again in %ds", l.perMinute, seconds)), nil
+		}
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(5)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		ok, _ := l.allow("a")
+		assert.True(t, ok, "call %d", i+1)
+	}
+	ok, wait := l.allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 12*time.Second, wait)
+
+	// Other clients have their own allowance.
+	ok, _ = l.allow("b")
+	assert.True(t, ok)
+
+	// Calls come back at 5 a minute.
+	now = now.Add(12 * time.Second)
+	ok, _ = l.allow("a")
+	assert.True(t, ok)
+	ok, _ = l.allow("a")
+	assert.False(t, ok)
+}
+
+func TestRateLimiterForgetsIdleClients(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(5)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		l.allow(fmt.Sprintf("client-%d", i))
+	}
+	assert.Len(t, l.buckets, 100)
+
+	now = now.Add(time.Minute)
+	l.allow("client-0")
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestRateLimiterBoundsClients(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(5)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < maxRateLimitClients+10; i++ {
+		now = now.Add(time.Millisecond)
+		l.allow(fmt.Sprintf("client-%d", i))
+	}
+	assert.Len(t, l.buckets, maxRateLimitClients)
+	assert.NotContains(t, l.buckets, "client-0")
+	assert.Contains(t, l.buckets, fmt.Sprintf("client-%d", maxRateLimitClients+9))
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	l := newRateLimiter(3)
+	handler := l.middleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	request := httptest.NewRequest("POST", "/mcp", nil)
+	request.RemoteAddr = "203.0.113.7:51234"
+	ctx := rememberClientAddr(context.Background(), request)
+	assert.Equal(t, "addr:203.0.113.7", rateLimitClient(ctx))
+
+	// A burst of calls from one address: the first three run, the rest are
+	// refused.
+	var allowed, refused int
+	for i := 0; i < 10; i++ {
+		result, err := handler(ctx, mcp.CallToolRequest{})
+		require.NoError(t, err)
+		if result.IsError {
+			refused++
+			assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "Rate limit exceeded: at most 3 tool calls per minute are allowed; try again in ")
+		} else {
+			allowed++
+		}
+	}
+	assert.Equal(t, 3, allowed)
+	assert.Equal(t, 7, refused)
+
+	// Another address, even on another port, is limited separately.
+	request.RemoteAddr = "198.51.100.2:40000"
+	result, err := handler(rememberClientAddr(context.Background(), request), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// staleConnectionMessages are fragments of the errors a pooled connection
+// returns after the server restarted or dropped it, for errors that arrive
+// as plain text rather than wrapped syscall errors.
+var staleConnectionMessages = []string{
+	"connection reset",
+	"broken pipe",
+	"server closed the connection",
+	"use of closed network connection",
+	"forcibly closed",
+	"bad connection",
+}
+
+// isStaleConnection reports whether err means the connection itself is dead,
+// as opposed to the query failing. Errors raised by SQL Server and timeouts
+// or cancellations never count.
+func isStaleConnection(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range staleConnectionMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// withReconnect calls run and, if it fails because the connection went
+// stale, calls discard to drop the cached pool. When retry is set it then
+// runs it exactly once more; otherwise the error is returned, since a
+// statement may have taken effect before the connection dropped.
+func withReconnect[T any](retry bool, run func() (T, error), discard func()) (T, error) {
+	result, err := run()
+	if !isStaleConnection(err) {
+		return result, err
+	}
+	discard()
+	if !retry {
+		return result, err
+	}
+	return run()
+}
+
+// discardConnection drops the cached pool for the named connection so the
+// next getConnection opens a fresh one. The old pool is closed in the
+// background, since Close waits for any query still using it.
+func (dm *DatabaseManager) discardConnection(name string) {
+	name = normalizeConnectionName(name)
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	p := dm.pools[name]
+	if p == nil || p.db == nil {
+		return
+	}
+	go p.db.Close()
+	p.db = nil
+}
+
+// reconnect replaces the cached pool for the named connection with a freshly
+// opened one, for operators after the database has been cycled. Queries,
+// transactions and sessions still using the old pool keep their connections
+// until they finish; the old pool is closed once they have. The circuit
+// breaker is reset so the attempt is made even if it had tripped.
+func (dm *DatabaseManager) reconnect(ctx context.Context, name string) (string, error) {
+	dm.discardConnection(name)
+
+	dm.mu.Lock()
+	if p := dm.pools[normalizeConnectionName(name)]; p != nil {
+		p.breaker.reset()
+	}
+	dm.mu.Unlock()
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: name}, "SELECT @@SERVERNAME, DB_NAME()")
+	if err != nil {
+		return "", err
+	}
+
+	label := normalizeConnectionName(name)
+	if label == "" {
+		label = defaultConnectionName
+	}
+	row := result.rows[0]
+	return fmt.Sprintf("Reconnected '%s' to server %s, database %s.", label, formatCell(row[0], defaultNullText), formatCell(row[1], defaultNullText)), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactedMask replaces secrets in connection strings. It matches the mask
+// used by url.URL.Redacted.
+const redactedMask = "xxxxx"
+
+// passwordKeys are the ADO-style connection string keys that hold secrets.
+var passwordKeys = map[string]bool{
+	"password": true,
+	"pwd":      true,
+}
+
+func isURLConnString(connString string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(connString)), "sqlserver://")
+}
+
+// connStringPasswords returns every secret value embedded in connString.
+func connStringPasswords(connString string) []string {
+	var passwords []string
+	if isURLConnString(connString) {
+		u, err := url.Parse(strings.TrimSpace(connString))
+		if err != nil {
+			return nil
+		}
+		if p, ok := u.User.Password(); ok && p != "" {
+			passwords = append(passwords, p)
+		}
+		for key, values := range u.Query() {
+			if passwordKeys[strings.ToLower(key)] {
+				for _, v := range values {
+					if v != "" {
+						passwords = append(passwords, v)
+					}
+				}
+			}
+		}
+		return passwords
+	}
+
+	for _, part := range strings.Split(connString, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || !passwordKeys[strings.ToLower(strings.TrimSpace(key))] {
+			continue
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			passwords = append(passwords, value)
+		}
+	}
+	return passwords
+}
+
+// redactConnString returns connString with any password masked. Both the
+// sqlserver:// URL form and the ADO key=value; form are supported.
+func redactConnString(connString string) string {
+	if isURLConnString(connString) {
+		u, err := url.Parse(strings.TrimSpace(connString))
+		if err != nil {
+			// Without a parse there is no telling where the password is.
+			return redactedMask
+		}
+		query := u.Query()
+		for key := range query {
+			if passwordKeys[strings.ToLower(key)] {
+				query.Set(key, redactedMask)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.Redacted()
+	}
+
+	parts := strings.Split(connString, ";")
+	for i, part := range parts {
+		key, _, ok := strings.Cut(part, "=")
+		if ok && passwordKeys[strings.ToLower(strings.TrimSpace(key))] {
+			parts[i] = key + "=" + redactedMask
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// redactSecrets masks every password from connString that appears in msg,
+// including inside an echoed copy of the connection string itself.
+func redactSecrets(msg, connString string) string {
+	if connString == "" {
+		return msg
+	}
+	msg = strings.ReplaceAll(msg, connString, redactConnString(connString))
+	for _, password := range connStringPasswords(connString) {
+		msg = strings.ReplaceAll(msg, password, redactedMask)
+		if escaped := url.QueryEscape(password); escaped != password {
+			msg = strings.ReplaceAll(msg, escaped, redactedMask)
+		}
+	}
+	return msg
+}
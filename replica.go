@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// replicaConnectionName is the pool key for the read-only replica of the
+// default connection. It cannot be produced by a MSSQL_CONN_<NAME> variable,
+// so it never shadows a configured connection.
+const replicaConnectionName = "default (replica)"
+
+// replicaConnectionString returns MSSQL_READONLY_CONNECTION_STRING, the
+// connection string of a read-only secondary, with ${VAR} references
+// expanded. It is empty when no replica is configured.
+func replicaConnectionString() (string, error) {
+	connString := os.Getenv("MSSQL_READONLY_CONNECTION_STRING")
+	if strings.TrimSpace(connString) == "" {
+		return "", nil
+	}
+	return expandEnvReferences(connString, "MSSQL_READONLY_CONNECTION_STRING")
+}
+
+// routeConnection picks the connection query runs on. A single read-only
+// SELECT on the default connection goes to the replica when one is
+// configured, using the same check that decides whether a query is safe to
+// retry; batches with further statements, everything else, and any
+// explicitly named connection are left where they were asked for.
+func routeConnection(connection, query string) string {
+	if normalizeConnectionName(connection) != "" || strings.TrimSpace(os.Getenv("MSSQL_READONLY_CONNECTION_STRING")) == "" {
+		return connection
+	}
+	if !isReadOnlyQuery(query) {
+		return connection
+	}
+	return replicaConnectionName
+}
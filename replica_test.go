@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteConnection(t *testing.T) {
+	t.Setenv("MSSQL_READONLY_CONNECTION_STRING", "")
+	assert.Equal(t, "", routeConnection("", "SELECT 1"))
+
+	t.Setenv("MSSQL_READONLY_CONNECTION_STRING", "server=replica;ApplicationIntent=ReadOnly")
+	assert.Equal(t, replicaConnectionName, routeConnection("", "SELECT * FROM dbo.orders"))
+	assert.Equal(t, replicaConnectionName, routeConnection("Default", "WITH c AS (SELECT 1 AS n) SELECT n FROM c"))
+	assert.Equal(t, "", routeConnection("", "UPDATE dbo.orders SET total = 0"))
+	assert.Equal(t, "", routeConnection("", "SELECT * INTO dbo.copy FROM dbo.orders"))
+	assert.Equal(t, "", routeConnection("", "EXEC dbo.refresh"))
+	assert.Equal(t, "reporting", routeConnection("reporting", "SELECT 1"))
+}
+
+func TestRouteConnectionKeepsBatchesOnPrimary(t *testing.T) {
+	t.Setenv("MSSQL_READONLY_CONNECTION_STRING", "server=replica;ApplicationIntent=ReadOnly")
+	for _, query := range []string{
+		"SELECT 1; DELETE FROM dbo.orders",
+		"SELECT 1; [DELETE] FROM dbo.orders",
+		"SELECT 1; SET IDENTITY_INSERT dbo.orders ON",
+		"SELECT 1; BACKUP DATABASE x TO DISK='c:\\x.bak'",
+		"SELECT 1; SELECT 2",
+		"SELECT 1 WAITFOR DELAY '0:0:5'",
+	} {
+		assert.Equal(t, "", routeConnection("", query), query)
+	}
+	assert.Equal(t, replicaConnectionName, routeConnection("", "SELECT 1;"))
+}
+
+func TestReplicaConnectionString(t *testing.T) {
+	t.Setenv("MSSQL_CONNECTION_STRING", "server=primary")
+	t.Setenv("REPLICA_PASSWORD", "secret")
+	t.Setenv("MSSQL_READONLY_CONNECTION_STRING", "server=replica;password=${REPLICA_PASSWORD}")
+
+	connString, err := connectionString(replicaConnectionName)
+	assert.NoError(t, err)
+	assert.Equal(t, "server=replica;password=secret", connString)
+
+	connString, err = connectionString("")
+	assert.NoError(t, err)
+	assert.Equal(t, "server=primary", connString)
+
+	t.Setenv("MSSQL_READONLY_CONNECTION_STRING", "server=replica;password=${MISSING_REPLICA_PASSWORD}")
+	_, err = connectionString(replicaConnectionName)
+	assert.EqualError(t, err, "MSSQL_READONLY_CONNECTION_STRING references ${MISSING_REPLICA_PASSWORD}, which is not set")
+}
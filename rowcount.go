@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// rowCount returns the number of rows in table as plain text. The table is
+// resolved against the catalog first so only real names reach the SQL text.
+// With approximate set, the count is read from sys.dm_db_partition_stats,
+// which is instant on huge tables but may lag behind in-flight changes and
+// needs VIEW DATABASE STATE permission.
+func rowCount(ctx context.Context, dm *DatabaseManager, connection, table string, approximate bool) (string, error) {
+	ref, err := resolveTable(ctx, dm, connection, table)
+	if err != nil {
+		return "", err
+	}
+
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+
+	opts := queryOptions{connection: connection}
+	if approximate {
+		// index_id 0 is the heap and 1 the clustered index; counting only
+		// those avoids adding up the rows of every nonclustered index.
+		result, err := runQuery(ctx, dm, opts, `SELECT SUM(row_count)
+FROM sys.dm_db_partition_stats
+WHERE object_id = OBJECT_ID(@p1) AND index_id IN (0, 1)`, tableName)
+		if err != nil {
+			return "", err
+		}
+		if len(result.rows) == 0 || result.rows[0][0] == nil {
+			return "", fmt.Errorf("no partition statistics for %s.%s; approximate counts are only available for tables", ref.schema, ref.name)
+		}
+		return formatValue(result.rows[0][0]), nil
+	}
+
+	result, err := runQuery(ctx, dm, opts, "SELECT COUNT_BIG(*) FROM "+tableName)
+	if err != nil {
+		return "", err
+	}
+	return formatValue(result.rows[0][0]), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+)
+
+// runningQueriesQuery lists executing requests with the statement each one is
+// currently running, longest-running first.
+const runningQueriesQuery = `SELECT r.session_id, s.login_name, DB_NAME(r.database_id) AS database_name,
+	r.status, r.command, r.wait_type, r.wait_time AS wait_ms, r.total_elapsed_time AS elapsed_ms,
+	r.blocking_session_id,
+	SUBSTRING(t.text, r.statement_start_offset / 2 + 1,
+		(CASE r.statement_end_offset WHEN -1 THEN DATALENGTH(t.text) ELSE r.statement_end_offset END
+			- r.statement_start_offset) / 2 + 1) AS current_sql
+FROM sys.dm_exec_requests r
+JOIN sys.dm_exec_sessions s ON s.session_id = r.session_id
+OUTER APPLY sys.dm_exec_sql_text(r.sql_handle) t
+WHERE r.session_id <> @@SPID`
+
+// listRunningQueries returns the requests currently executing on the
+// instance behind connection, leaving out this server's own session. Sessions
+// SQL Server runs for itself are only included when includeSystem is set.
+// Other users' requests are only visible with VIEW SERVER STATE permission.
+func listRunningQueries(ctx context.Context, dm *DatabaseManager, connection string, includeSystem bool) (string, error) {
+	query := runningQueriesQuery
+	if !includeSystem {
+		query += "\n\tAND s.is_user_process = 1"
+	}
+	query += "\nORDER BY r.total_elapsed_time DESC"
+
+	result, err := runQuery(ctx, dm, queryOptions{connection: connection}, query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.rows) == 0 {
+		return "No other queries are running.", nil
+	}
+
+	return formatTable(result.columns, result.types, result.rows, defaultNullText) + result.truncationNote(), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultSampleRows is how many rows sample_table asks for when the call
+// gives neither a percentage nor a row count.
+const defaultSampleRows = 100
+
+// sampleRequest describes a sample_table call. Exactly one of percent and
+// rows is used; percent wins when both are positive.
+type sampleRequest struct {
+	table   string
+	percent float64
+	rows    int
+	format  string
+}
+
+// sampleQuery builds the TABLESAMPLE query for an already quoted table and
+// column list. A percentage above 100 is lowered to 100 and a row count above
+// limit to limit. TABLESAMPLE sizes are approximate, so a row sample is also
+// capped with TOP.
+func sampleQuery(tableName string, columns []string, percent float64, rows, limit int) (string, error) {
+	selectList := strings.Join(columns, ", ")
+	switch {
+	case percent < 0 || rows < 0:
+		return "", fmt.Errorf("sample size must be positive")
+	case percent > 0:
+		percent = min(percent, 100)
+		return fmt.Sprintf("SELECT %s FROM %s TABLESAMPLE (%s PERCENT)",
+			selectList, tableName, strconv.FormatFloat(percent, 'f', -1, 64)), nil
+	}
+	if rows == 0 {
+		rows = defaultSampleRows
+	}
+	rows = min(rows, limit)
+	return fmt.Sprintf("SELECT TOP (%d) %s FROM %s TABLESAMPLE (%d ROWS)", rows, selectList, tableName, rows), nil
+}
+
+// sampleTable returns an approximate random sample of a table's rows using
+// TABLESAMPLE, which reads whole data pages and so costs far less than
+// ORDER BY NEWID() on a large table. The table is resolved against the
+// catalog first. Small tables may yield an empty or uneven sample, and views
+// cannot be sampled.
+func sampleTable(ctx context.Context, dm *DatabaseManager, opts queryOptions, sample sampleRequest) (string, error) {
+	ref, err := resolveTable(ctx, dm, opts.connection, sample.table)
+	if err != nil {
+		return "", err
+	}
+	tableName, err := ref.quotedName()
+	if err != nil {
+		return "", err
+	}
+	columns := make([]string, len(ref.columns))
+	for i, col := range ref.columns {
+		if columns[i], err = quoteIdentifier(col); err != nil {
+			return "", err
+		}
+	}
+
+	limit := opts.maxRows
+	if limit <= 0 {
+		limit = maxRowsLimit()
+	}
+	query, err := sampleQuery(tableName, columns, sample.percent, sample.rows, limit)
+	if err != nil {
+		return "", err
+	}
+	return executeQuery(ctx, dm, opts, query, nil, sample.format)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleQuery(t *testing.T) {
+	columns := []string{"[id]", "[name]"}
+
+	query, err := sampleQuery("[dbo].[orders]", columns, 2.5, 0, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT [id], [name] FROM [dbo].[orders] TABLESAMPLE (2.5 PERCENT)", query)
+
+	query, err = sampleQuery("[dbo].[orders]", columns, 250, 0, 1000)
+	require.NoError(t, err)
+	assert.Contains(t, query, "TABLESAMPLE (100 PERCENT)")
+
+	query, err = sampleQuery("[dbo].[orders]", columns, 0, 20, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT TOP (20) [id], [name] FROM [dbo].[orders] TABLESAMPLE (20 ROWS)", query)
+
+	query, err = sampleQuery("[dbo].[orders]", columns, 0, 5000, 1000)
+	require.NoError(t, err)
+	assert.Contains(t, query, "TOP (1000)")
+	assert.Contains(t, query, "(1000 ROWS)")
+
+	query, err = sampleQuery("[dbo].[orders]", columns, 0, 0, 1000)
+	require.NoError(t, err)
+	assert.Contains(t, query, "TOP (100)")
+
+	_, err = sampleQuery("[dbo].[orders]", columns, -1, 0, 1000)
+	assert.Error(t, err)
+	_, err = sampleQuery("[dbo].[orders]", columns, 0, -5, 1000)
+	assert.Error(t, err)
+}
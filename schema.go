@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	schemaResourceURI     = "mssql://schema"
+	defaultSchemaCacheTTL = 60 * time.Second
+	// schemaMaxRows bounds the column listing independently of MSSQL_MAX_ROWS,
+	// which is sized for ad-hoc query output rather than catalog reads.
+	schemaMaxRows = 100000
+)
+
+const schemaQuery = `SELECT t.TABLE_SCHEMA, t.TABLE_NAME, t.TABLE_TYPE, c.COLUMN_NAME, c.DATA_TYPE,
+	c.CHARACTER_MAXIMUM_LENGTH, c.NUMERIC_PRECISION, c.NUMERIC_SCALE, c.IS_NULLABLE
+FROM INFORMATION_SCHEMA.TABLES t
+JOIN INFORMATION_SCHEMA.COLUMNS c ON c.TABLE_SCHEMA = t.TABLE_SCHEMA AND c.TABLE_NAME = t.TABLE_NAME
+ORDER BY t.TABLE_SCHEMA, t.TABLE_NAME, c.ORDINAL_POSITION`
+
+// schemaCacheTTL returns how long a schema snapshot is reused, from
+// MSSQL_SCHEMA_CACHE_SECONDS. Zero disables caching.
+func schemaCacheTTL() time.Duration {
+	seconds := intEnv("MSSQL_SCHEMA_CACHE_SECONDS", -1, 0)
+	if seconds < 0 {
+		return defaultSchemaCacheTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// schemaCache holds the most recent schema document so repeated resource
+// reads do not re-query the catalog.
+type schemaCache struct {
+	dm *DatabaseManager
+
+	mu       sync.Mutex
+	document string
+	loaded   time.Time
+}
+
+func newSchemaCache(dm *DatabaseManager) *schemaCache {
+	return &schemaCache{dm: dm}
+}
+
+// get returns the cached schema document, reloading it once the TTL expires.
+func (c *schemaCache) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.document != "" && time.Since(c.loaded) < schemaCacheTTL() {
+		return c.document, nil
+	}
+
+	document, err := describeSchema(ctx, c.dm)
+	if err != nil {
+		return "", err
+	}
+	c.document = document
+	c.loaded = time.Now()
+	return document, nil
+}
+
+// columnTypeName renders a SQL type with its length or precision, e.g.
+// nvarchar(100), varchar(max) or decimal(18,2).
+func columnTypeName(dataType string, maxLength, precision, scale interface{}) string {
+	switch strings.ToLower(dataType) {
+	case "char", "varchar", "nchar", "nvarchar", "binary", "varbinary":
+		if length, ok := maxLength.(int64); ok {
+			if length == -1 {
+				return dataType + "(max)"
+			}
+			return fmt.Sprintf("%s(%d)", dataType, length)
+		}
+	case "decimal", "numeric":
+		if p, ok := precision.(int64); ok {
+			s, _ := scale.(int64)
+			return fmt.Sprintf("%s(%d,%d)", dataType, p, s)
+		}
+	}
+	return dataType
+}
+
+// describeSchema lists every table and view on the default connection with
+// its columns, one indented line per column.
+func describeSchema(ctx context.Context, dm *DatabaseManager) (string, error) {
+	result, err := runQuery(ctx, dm, queryOptions{maxRows: schemaMaxRows}, schemaQuery)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.rows) == 0 {
+		return "No tables found.", nil
+	}
+
+	var output strings.Builder
+	current := ""
+	for _, row := range result.rows {
+		table := formatValue(row[0]) + "." + formatValue(row[1])
+		if table != current {
+			if current != "" {
+				output.WriteString("\n")
+			}
+			fmt.Fprintf(&output, "%s (%s)\n", table, formatValue(row[2]))
+			current = table
+		}
+
+		nullability := "NOT NULL"
+		if formatValue(row[8]) == "YES" {
+			nullability = "NULL"
+		}
+		fmt.Fprintf(&output, "  %s %s %s\n", formatValue(row[3]), columnTypeName(formatValue(row[4]), row[5], row[6], row[7]), nullability)
+	}
+
+	return output.String() + result.truncationNote(), nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnTypeName(t *testing.T) {
+	assert.Equal(t, "nvarchar(100)", columnTypeName("nvarchar", int64(100), nil, nil))
+	assert.Equal(t, "varbinary(max)", columnTypeName("varbinary", int64(-1), nil, nil))
+	assert.Equal(t, "decimal(18,2)", columnTypeName("decimal", nil, int64(18), int64(2)))
+	assert.Equal(t, "int", columnTypeName("int", nil, int64(10), int64(0)))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goSeparator matches a batch separator line: GO on its own, optionally
+// followed by a repeat count and a trailing comment.
+var goSeparator = regexp.MustCompile(`(?i)^\s*GO(?:\s+(\d+))?\s*(?:--.*)?$`)
+
+// scriptBatch is one batch of a script and the count given after its GO.
+type scriptBatch struct {
+	text  string
+	count int
+}
+
+// sqlScanState tracks the constructs that can span lines in T-SQL, so a GO
+// line inside a string literal, quoted identifier or block comment is not
+// taken for a separator.
+type sqlScanState struct {
+	quote        rune // closing character of the open literal or identifier
+	commentDepth int  // T-SQL block comments nest
+}
+
+// scanLine advances the state over one line of SQL.
+func (s *sqlScanState) scanLine(line string) {
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case s.commentDepth > 0:
+			if r == '*' && next == '/' {
+				s.commentDepth--
+				i++
+			} else if r == '/' && next == '*' {
+				s.commentDepth++
+				i++
+			}
+		case s.quote != 0:
+			if r == s.quote {
+				if next == s.quote {
+					i++
+				} else {
+					s.quote = 0
+				}
+			}
+		case r == '-' && next == '-':
+			return
+		case r == '/' && next == '*':
+			s.commentDepth++
+			i++
+		case r == '\'' || r == '"':
+			s.quote = r
+		case r == '[':
+			s.quote = ']'
+		}
+	}
+}
+
+// splitScript splits a T-SQL script into batches on GO lines, the way sqlcmd
+// and SSMS do. GO is only recognized on a line of its own outside strings,
+// quoted identifiers and comments. Empty batches are dropped.
+func splitScript(script string) []scriptBatch {
+	var batches []scriptBatch
+	var current strings.Builder
+	var state sqlScanState
+
+	flush := func(count int) {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			batches = append(batches, scriptBatch{text: text, count: count})
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		if state.quote == 0 && state.commentDepth == 0 {
+			if match := goSeparator.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+				count := 1
+				if match[1] != "" {
+					if n, err := strconv.Atoi(match[1]); err == nil && n > 0 {
+						count = n
+					}
+				}
+				flush(count)
+				continue
+			}
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		state.scanLine(line)
+	}
+	flush(1)
+
+	return batches
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitScript(t *testing.T) {
+	script := "CREATE TABLE #t (id int)\n" +
+		"GO\n" +
+		"INSERT INTO #t VALUES (1)\r\n" +
+		"  go 3  -- seed rows\r\n" +
+		"SELECT 'line one\nGO\nline three' AS text_with_go\n" +
+		"/* a comment\nGO\n/* nested */ still comment */\n" +
+		"SELECT [weird\nGO] FROM #t\n" +
+		"GO\n" +
+		"SELECT going, GOTO_label FROM #t -- GO\n" +
+		"GO\n" +
+		"GO\n"
+
+	batches := splitScript(script)
+	assert.Equal(t, []scriptBatch{
+		{text: "CREATE TABLE #t (id int)", count: 1},
+		{text: "INSERT INTO #t VALUES (1)", count: 3},
+		{text: "SELECT 'line one\nGO\nline three' AS text_with_go\n/* a comment\nGO\n/* nested */ still comment */\nSELECT [weird\nGO] FROM #t", count: 1},
+		{text: "SELECT going, GOTO_label FROM #t -- GO", count: 1},
+	}, batches)
+
+	assert.Equal(t, []scriptBatch{{text: "SELECT 1", count: 1}}, splitScript("SELECT 1"))
+	assert.Empty(t, splitScript("GO\n\nGO"))
+}
@@ -0,0 +1,52 @@
+package main
+
+// selectStarWarning is prepended to results when MSSQL_WARN_SELECT_STAR is set
+// and the query reads every column of every row.
+const selectStarWarning = "-- Warning: this query uses SELECT * with no WHERE, TOP or OFFSET. On large tables, list only the columns you need and limit the rows returned. --\n"
+
+// warnSelectStar reports whether MSSQL_WARN_SELECT_STAR asks for advice on
+// unfiltered SELECT * queries.
+func warnSelectStar() bool {
+	return boolEnv("MSSQL_WARN_SELECT_STAR")
+}
+
+// isUnboundedSelectStar reports whether query selects * (or alias.*) and
+// contains no WHERE, TOP or OFFSET anywhere in the batch. A * inside
+// parentheses, as in COUNT(*), and EXISTS (SELECT * ...) subqueries do not
+// count, and comments, literals and quoted identifiers are ignored.
+func isUnboundedSelectStar(query string) bool {
+	tokens := sqlTokensWithSymbols(query)
+	star := false
+	for i, token := range tokens {
+		switch token {
+		case "where", "top", "offset":
+			return false
+		case "*":
+			star = star || isSelectListStar(tokens, i)
+		}
+	}
+	return star
+}
+
+// isSelectListStar reports whether the * at tokens[i] is a select-list
+// wildcard rather than multiplication or part of COUNT(*).
+func isSelectListStar(tokens []string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	switch tokens[i-1] {
+	case ".":
+		// Only alias.* follows a dot; a.b * c has the identifier before it.
+		return true
+	case ",":
+		return true
+	case "select", "distinct", "all":
+		// Walk back to the SELECT to see whether it opens an EXISTS test.
+		j := i - 1
+		for j > 0 && tokens[j] != "select" {
+			j--
+		}
+		return !(j >= 2 && tokens[j-1] == "(" && tokens[j-2] == "exists")
+	}
+	return false
+}
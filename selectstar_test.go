@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnboundedSelectStar(t *testing.T) {
+	flagged := []string{
+		"SELECT * FROM dbo.orders",
+		"select distinct * from dbo.orders",
+		"SELECT o.* FROM dbo.orders o JOIN dbo.customers c ON c.id = o.customer_id",
+		"SELECT [o].* FROM dbo.orders [o]",
+		"SELECT id, * FROM dbo.orders ORDER BY id",
+		"SELECT COUNT(*) FROM dbo.orders; SELECT * FROM dbo.orders",
+	}
+	for _, query := range flagged {
+		assert.True(t, isUnboundedSelectStar(query), query)
+	}
+
+	allowed := []string{
+		"SELECT COUNT(*) FROM dbo.orders",
+		"SELECT id, price * quantity AS total FROM dbo.orders",
+		"SELECT * FROM dbo.orders WHERE id = 1",
+		"SELECT TOP 10 * FROM dbo.orders",
+		"SELECT * FROM dbo.orders ORDER BY id OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY",
+		"IF EXISTS (SELECT * FROM dbo.orders) PRINT 'yes'",
+		"SELECT id FROM dbo.orders -- SELECT * FROM dbo.orders",
+		"SELECT 'SELECT * FROM x' AS q",
+		"SELECT [*] FROM dbo.odd",
+	}
+	for _, query := range allowed {
+		assert.False(t, isUnboundedSelectStar(query), query)
+	}
+}
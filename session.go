@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sessionResetTimeout = 5 * time.Second
+
+// checkNoSessionOptionOverride rejects text that changes NOEXEC, PARSEONLY or
+// a SHOWPLAN option. Tools that run user text under one of those options to
+// keep it from executing would otherwise let "SET NOEXEC OFF; ..." run for
+// real.
+func checkNoSessionOptionOverride(query string) error {
+	for _, token := range sqlTokens(query) {
+		if token == "noexec" || token == "parseonly" || strings.HasPrefix(token, "showplan_") {
+			return fmt.Errorf("query rejected: it may not change SET %s, which this tool relies on to keep the query from executing", strings.ToUpper(token))
+		}
+	}
+	return nil
+}
+
+// withSessionOption runs fn on a dedicated connection with the named SET
+// option (e.g. SHOWPLAN_XML) switched on. These options must be the only
+// statement in their batch and persist for the session, so the connection is
+// discarded rather than returned to the pool if the option cannot be
+// switched back off.
+func withSessionOption(ctx context.Context, dm *DatabaseManager, connection string, timeout time.Duration, option string, fn func(ctx context.Context, conn *sql.Conn) error) error {
+	db, err := dm.getConnection(connection)
+	if err != nil {
+		return fmt.Errorf("database connection unavailable: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return wrapQueryError(ctx, timeout, "failed to reserve a connection", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET "+option+" ON"); err != nil {
+		return wrapQueryError(ctx, timeout, "failed to enable "+option, err)
+	}
+	defer func() {
+		resetCtx, resetCancel := context.WithTimeout(context.Background(), sessionResetTimeout)
+		defer resetCancel()
+		if _, err := conn.ExecContext(resetCtx, "SET "+option+" OFF"); err != nil {
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	return fn(ctx, conn)
+}
+
+const defaultSessionIdleTimeout = 10 * time.Minute
+
+// sessionIdleTimeout returns how long a session opened with open_session may
+// go unused before its connection is returned to the pool, from
+// MSSQL_SESSION_IDLE_TIMEOUT_SECONDS.
+func sessionIdleTimeout() time.Duration {
+	seconds := intEnv("MSSQL_SESSION_IDLE_TIMEOUT_SECONDS", 0, 1)
+	if seconds == 0 {
+		return defaultSessionIdleTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// pinnedSession is a named session opened with open_session. It holds one
+// connection out of the pool so temp tables and SET options survive from one
+// execute_sql call to the next.
+type pinnedSession struct {
+	connection string
+	conn       *sql.Conn
+	idle       *time.Timer
+
+	// mu serializes statements on the connection and guards closed.
+	mu     sync.Mutex
+	closed bool
+}
+
+// openSession reserves a connection from the named pool for the session
+// called name. The session is closed after MSSQL_SESSION_IDLE_TIMEOUT_SECONDS
+// without a statement, or when the server shuts down.
+func (dm *DatabaseManager) openSession(ctx context.Context, name, connection string) (time.Duration, error) {
+	dm.mu.RLock()
+	_, exists := dm.sessions[name]
+	dm.mu.RUnlock()
+	if exists {
+		return 0, fmt.Errorf("session '%s' is already open", name)
+	}
+
+	db, err := dm.getConnection(connection)
+	if err != nil {
+		return 0, fmt.Errorf("database connection unavailable: %v", err)
+	}
+	timeout := pingTimeout()
+	reserveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := db.Conn(reserveCtx)
+	if err != nil {
+		return 0, wrapQueryError(reserveCtx, timeout, "failed to reserve a connection", err)
+	}
+
+	s := &pinnedSession{connection: connection, conn: conn}
+	dm.mu.Lock()
+	if _, exists := dm.sessions[name]; exists {
+		dm.mu.Unlock()
+		conn.Close()
+		return 0, fmt.Errorf("session '%s' is already open", name)
+	}
+	// The timer is set before dm.mu is released so nobody who finds the
+	// session can see it without one. Both callbacks only close this
+	// session, not a newer one opened later under the same name.
+	idle := sessionIdleTimeout()
+	s.idle = time.AfterFunc(idle, func() { dm.closePinnedSession(name, s) })
+	dm.sessions[name] = s
+	dm.mu.Unlock()
+	context.AfterFunc(dm.shutdownCtx, func() { dm.closePinnedSession(name, s) })
+
+	return idle, nil
+}
+
+// closeSession returns the session's connection to the pool and forgets the
+// session. Temp tables it created are dropped by the connection reset when
+// the pool hands the connection out again.
+func (dm *DatabaseManager) closeSession(name string) error {
+	return dm.closePinnedSession(name, nil)
+}
+
+// closePinnedSession closes the session called name, provided it is want; a
+// nil want closes whichever session has that name.
+func (dm *DatabaseManager) closePinnedSession(name string, want *pinnedSession) error {
+	dm.mu.Lock()
+	s, ok := dm.sessions[name]
+	if ok && want != nil && s != want {
+		ok = false
+	}
+	if ok {
+		delete(dm.sessions, name)
+	}
+	dm.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no open session named '%s'; it may have been closed or expired", name)
+	}
+
+	s.idle.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.conn.Close()
+}
+
+// inSession runs fn on the connection pinned by the named session, one call
+// at a time, and restarts the session's idle timer once fn returns.
+func (dm *DatabaseManager) inSession(name string, fn func(connection string, conn *sql.Conn) (string, error)) (string, error) {
+	dm.mu.RLock()
+	s, ok := dm.sessions[name]
+	dm.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no open session named '%s'; it may have been closed or expired", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", fmt.Errorf("no open session named '%s'; it may have been closed or expired", name)
+	}
+	s.idle.Stop()
+	defer s.idle.Reset(sessionIdleTimeout())
+
+	return fn(s.connection, s.conn)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionIdleTimeout(t *testing.T) {
+	t.Setenv("MSSQL_SESSION_IDLE_TIMEOUT_SECONDS", "")
+	assert.Equal(t, defaultSessionIdleTimeout, sessionIdleTimeout())
+
+	t.Setenv("MSSQL_SESSION_IDLE_TIMEOUT_SECONDS", "90")
+	assert.Equal(t, 90*time.Second, sessionIdleTimeout())
+}
+
+func TestUnknownSession(t *testing.T) {
+	dm := NewDatabaseManager()
+	assert.ErrorContains(t, dm.closeSession("scratch"), "no open session named 'scratch'")
+
+	_, err := dm.inSession("scratch", func(string, *sql.Conn) (string, error) {
+		t.Fatal("fn must not run without a session")
+		return "", nil
+	})
+	assert.ErrorContains(t, err, "no open session named 'scratch'")
+}
+
+func TestStaleSessionTimerKeepsNewerSession(t *testing.T) {
+	dm := NewDatabaseManager()
+	stale := &pinnedSession{idle: time.NewTimer(time.Hour)}
+	current := &pinnedSession{idle: time.NewTimer(time.Hour)}
+	defer stale.idle.Stop()
+	defer current.idle.Stop()
+	dm.sessions["scratch"] = current
+
+	// The idle timer or shutdown hook of a session that was already closed
+	// must not close a newer session opened under the same name.
+	assert.Error(t, dm.closePinnedSession("scratch", stale))
+	assert.Same(t, current, dm.sessions["scratch"])
+}
+
+func TestCheckNoSessionOptionOverride(t *testing.T) {
+	for _, query := range []string{
+		"SET NOEXEC OFF; EXEC xp_cmdshell 'dir'",
+		"set parseonly off\nDELETE FROM dbo.orders",
+		"SET SHOWPLAN_XML OFF",
+		"SET NOCOUNT ON, NOEXEC OFF",
+	} {
+		assert.Error(t, checkNoSessionOptionOverride(query), query)
+	}
+	for _, query := range []string{
+		"SELECT id FROM dbo.orders",
+		"SET NOCOUNT ON; SELECT 1",
+		"SELECT 'SET NOEXEC OFF' AS note -- set parseonly off",
+	} {
+		assert.NoError(t, checkNoSessionOptionOverride(query), query)
+	}
+}
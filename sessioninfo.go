@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// sessionInfo reports who the connection is operating as: the login, the
+// database user it maps to, the current database, the server name, and the
+// database roles the user is a direct member of. It is meant for diagnosing
+// "permission denied" errors.
+func sessionInfo(ctx context.Context, dm *DatabaseManager, connection string) (string, error) {
+	opts := queryOptions{connection: connection}
+	identity, err := runQuery(ctx, dm, opts, "SELECT SUSER_SNAME(), USER_NAME(), DB_NAME(), @@SERVERNAME")
+	if err != nil {
+		return "", err
+	}
+	if len(identity.rows) == 0 {
+		return "", fmt.Errorf("session information query returned no rows")
+	}
+
+	roles, err := runQuery(ctx, dm, opts, `SELECT r.name
+FROM sys.database_role_members m
+JOIN sys.database_principals r ON r.principal_id = m.role_principal_id
+WHERE m.member_principal_id = DATABASE_PRINCIPAL_ID()
+ORDER BY r.name`)
+	if err != nil {
+		return "", err
+	}
+	roleNames := make([]string, 0, len(roles.rows))
+	for _, row := range roles.rows {
+		roleNames = append(roleNames, formatValue(row[0]))
+	}
+
+	row := identity.rows[0]
+	return formatKeyValues([][2]string{
+		{"login", formatCell(row[0], defaultNullText)},
+		{"user", formatCell(row[1], defaultNullText)},
+		{"database", formatCell(row[2], defaultNullText)},
+		{"server", formatCell(row[3], defaultNullText)},
+		{"roles", rolesText(roleNames)},
+	}), nil
+}
+
+// rolesText lists role names for sessionInfo, noting when there are none
+// beyond the implicit public role.
+func rolesText(roles []string) string {
+	if len(roles) == 0 {
+		return "(none besides public)"
+	}
+	return strings.Join(roles, ", ")
+}
+
+// formatKeyValues renders pairs as "key: value" lines with the values
+// aligned.
+func formatKeyValues(pairs [][2]string) string {
+	width := 0
+	for _, pair := range pairs {
+		width = max(width, len(pair[0]))
+	}
+
+	var output strings.Builder
+	for _, pair := range pairs {
+		fmt.Fprintf(&output, "%-*s %s\n", width+1, pair[0]+":", pair[1])
+	}
+	return output.String()
+}
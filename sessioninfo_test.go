@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatKeyValues(t *testing.T) {
+	assert.Equal(t, "login:    sa\ndatabase: master\n", formatKeyValues([][2]string{{"login", "sa"}, {"database", "master"}}))
+}
+
+func TestRolesText(t *testing.T) {
+	assert.Equal(t, "(none besides public)", rolesText(nil))
+	assert.Equal(t, "db_datareader, db_datawriter", rolesText([]string{"db_datareader", "db_datawriter"}))
+}
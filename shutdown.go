@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const defaultShutdownGrace = 20 * time.Second
+
+// shutdownGrace returns how long in-flight queries may keep running after a
+// shutdown signal, from MSSQL_SHUTDOWN_GRACE_SECONDS.
+func shutdownGrace() time.Duration {
+	seconds := intEnv("MSSQL_SHUTDOWN_GRACE_SECONDS", -1, 0)
+	if seconds < 0 {
+		return defaultShutdownGrace
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// trackToolCalls is tool middleware that rejects calls once draining has
+// begun and counts the rest as in flight. Each call's context is also
+// cancelled if the grace period runs out before it finishes.
+func (dm *DatabaseManager) trackToolCalls(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		dm.mu.Lock()
+		if dm.draining {
+			dm.mu.Unlock()
+			return mcp.NewToolResultError("Server is shutting down; no new queries are accepted"), nil
+		}
+		dm.inflight.Add(1)
+		dm.mu.Unlock()
+		defer dm.inflight.Done()
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(dm.shutdownCtx, cancel)
+		defer stop()
+
+		return next(ctx, request)
+	}
+}
+
+// drain stops new tool calls and waits up to grace for in-flight calls and
+// async queries to finish. Anything still running afterwards is cancelled.
+func (dm *DatabaseManager) drain(grace time.Duration) {
+	dm.mu.Lock()
+	dm.draining = true
+	dm.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		dm.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+
+	fmt.Fprintf(os.Stderr, "Shutdown grace period of %v expired; cancelling remaining queries\n", grace)
+	dm.cancelShutdown()
+
+	// Give cancelled queries a moment to unwind so the pools close cleanly.
+	select {
+	case <-done:
+	case <-time.After(sessionResetTimeout):
+	}
+}
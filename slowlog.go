@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// slowPlanTimeout bounds the background plan fetch for a slow query so a
+// struggling server is not kept busy by diagnostics.
+const slowPlanTimeout = 5 * time.Second
+
+// slowQueryThreshold returns the duration from MSSQL_SLOW_QUERY_MS above which
+// queries are logged, or zero when slow query logging is disabled.
+func slowQueryThreshold() time.Duration {
+	return time.Duration(intEnv("MSSQL_SLOW_QUERY_MS", 0, 1)) * time.Millisecond
+}
+
+// logIfSlow logs query to stderr along with its estimated plan when it ran
+// for longer than MSSQL_SLOW_QUERY_MS since start. The plan is fetched in the
+// background, so the caller's response is never delayed or altered. It is
+// meant to be deferred with the query's start time.
+func logIfSlow(dm *DatabaseManager, opts queryOptions, query string, args []interface{}, start time.Time) {
+	threshold := slowQueryThreshold()
+	elapsed := time.Since(start)
+	if threshold == 0 || elapsed < threshold {
+		return
+	}
+
+	go func() {
+		var plan string
+		switch {
+		case len(args) > 0:
+			plan = "not fetched for parameterized queries"
+		case opts.database != "":
+			plan = "not fetched for queries run in another database"
+		default:
+			ctx, cancel := context.WithTimeout(dm.shutdownCtx, slowPlanTimeout)
+			defer cancel()
+
+			var err error
+			plan, err = explainQuery(ctx, dm, opts.connection, query)
+			if err != nil {
+				plan = fmt.Sprintf("unavailable: %v", err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Slow query (%v, threshold %v): %q\nEstimated plan: %s\n", elapsed.Round(time.Millisecond), threshold, query, plan)
+	}()
+}
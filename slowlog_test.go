@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryThreshold(t *testing.T) {
+	t.Setenv("MSSQL_SLOW_QUERY_MS", "")
+	assert.Equal(t, time.Duration(0), slowQueryThreshold(), "unset disables slow query logging")
+
+	t.Setenv("MSSQL_SLOW_QUERY_MS", "250")
+	assert.Equal(t, 250*time.Millisecond, slowQueryThreshold())
+
+	t.Setenv("MSSQL_SLOW_QUERY_MS", "-1")
+	assert.Equal(t, time.Duration(0), slowQueryThreshold())
+}
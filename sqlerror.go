@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sqlServerError returns the SQL Server error in err's chain, if any.
+func sqlServerError(err error) (mssql.Error, bool) {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr, true
+	}
+	return mssql.Error{}, false
+}
+
+// sqlErrorDetails returns the number, severity and state of the SQL Server
+// error in err's chain as a suffix for error messages, or an empty string for
+// errors that did not come from the server.
+func sqlErrorDetails(err error) string {
+	sqlErr, ok := sqlServerError(err)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" (error %d, severity %d, state %d)", sqlErr.Number, sqlErr.Class, sqlErr.State)
+}
+
+// queryErrorResult reports a failed query to the client. In JSON and NDJSON
+// formats the error is an object with the SQL Server number, severity and
+// state as separate fields; otherwise it is the usual "Error: ..." text.
+func queryErrorResult(err error, format string) *mcp.CallToolResult {
+	if format != formatJSON && format != formatNDJSON {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+	}
+
+	details := map[string]interface{}{"message": err.Error()}
+	if sqlErr, ok := sqlServerError(err); ok {
+		details["number"] = sqlErr.Number
+		details["severity"] = sqlErr.Class
+		details["state"] = sqlErr.State
+		if sqlErr.ProcName != "" {
+			details["procedure"] = sqlErr.ProcName
+		}
+		details["line"] = sqlErr.LineNo
+	}
+	encoded, jsonErr := json.Marshal(map[string]interface{}{"error": details})
+	if jsonErr != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("Error: %v", err))
+	}
+	return mcp.NewToolResultText(string(encoded))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLErrorDetails(t *testing.T) {
+	deadlock := mssql.Error{Number: 1205, Class: 13, State: 51, Message: "Transaction was deadlocked"}
+	err := fmt.Errorf("query execution failed: %w", deadlock)
+
+	assert.Equal(t, " (error 1205, severity 13, state 51)", sqlErrorDetails(err))
+	assert.Equal(t, "", sqlErrorDetails(fmt.Errorf("connection refused")))
+}
+
+func TestQueryErrorResultJSON(t *testing.T) {
+	denied := mssql.Error{Number: 229, Class: 14, State: 5, Message: "The SELECT permission was denied", LineNo: 1}
+	result := queryErrorResult(fmt.Errorf("query execution failed: %w", denied), formatJSON)
+
+	var decoded struct {
+		Error struct {
+			Message  string `json:"message"`
+			Number   int    `json:"number"`
+			Severity int    `json:"severity"`
+			State    int    `json:"state"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &decoded))
+	assert.Equal(t, 229, decoded.Error.Number)
+	assert.Equal(t, 14, decoded.Error.Severity)
+	assert.Equal(t, 5, decoded.Error.State)
+	assert.Contains(t, decoded.Error.Message, "permission was denied")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultTableStatsLimit is how many tables table_stats lists when no table
+// is given.
+const defaultTableStatsLimit = 20
+
+// tableStatsColumns are the columns table_stats reports. Sizes are computed
+// from sys.dm_db_partition_stats the way sp_spaceused does: data is the heap
+// or clustered index pages including LOB and row-overflow pages, and index
+// is every other used page.
+const tableStatsColumns = `s.name AS schema_name, t.name AS table_name,
+       SUM(CASE WHEN ps.index_id < 2 THEN ps.row_count ELSE 0 END) AS row_count,
+       SUM(ps.reserved_page_count) * 8 AS reserved_kb,
+       SUM(ps.used_page_count) * 8 AS used_kb,
+       SUM(CASE WHEN ps.index_id < 2 THEN ps.in_row_data_page_count + ps.lob_used_page_count + ps.row_overflow_used_page_count ELSE 0 END) * 8 AS data_kb,
+       (SUM(ps.used_page_count) - SUM(CASE WHEN ps.index_id < 2 THEN ps.in_row_data_page_count + ps.lob_used_page_count + ps.row_overflow_used_page_count ELSE 0 END)) * 8 AS index_kb
+FROM sys.dm_db_partition_stats ps
+JOIN sys.tables t ON t.object_id = ps.object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id`
+
+// tableStats reports the row count and reserved, used, data and index space
+// in KB of table, or of the limit largest tables by reserved space when table
+// is empty, as a text table. A given table is resolved against the catalog
+// first. Reading partition stats needs VIEW DATABASE STATE permission.
+func tableStats(ctx context.Context, dm *DatabaseManager, connection, table string, limit int) (string, error) {
+	opts := queryOptions{connection: connection, maxRows: schemaMaxRows}
+	var result *queryResult
+	if table != "" {
+		ref, err := resolveTable(ctx, dm, connection, table)
+		if err != nil {
+			return "", err
+		}
+		tableName, err := ref.quotedName()
+		if err != nil {
+			return "", err
+		}
+		result, err = runQuery(ctx, dm, opts, "SELECT "+tableStatsColumns+`
+WHERE ps.object_id = OBJECT_ID(@p1)
+GROUP BY s.name, t.name`, tableName)
+		if err != nil {
+			return "", err
+		}
+		if len(result.rows) == 0 {
+			return "", fmt.Errorf("no storage statistics for %s.%s; they are only available for tables", ref.schema, ref.name)
+		}
+	} else {
+		if limit <= 0 {
+			limit = defaultTableStatsLimit
+		}
+		var err error
+		result, err = runQuery(ctx, dm, opts, "SELECT TOP (@p1) "+tableStatsColumns+`
+GROUP BY s.name, t.name
+ORDER BY reserved_kb DESC, s.name, t.name`, limit)
+		if err != nil {
+			return "", err
+		}
+		if len(result.rows) == 0 {
+			return "No tables found.", nil
+		}
+	}
+
+	return formatTable(result.columns, result.types, result.rows, defaultNullText) + result.truncationNote(), nil
+}
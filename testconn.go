@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// testConnectionAllowed reports whether MSSQL_ALLOW_TEST_CONNECTION lets
+// test_connection open connections. It is off by default since the tool
+// connects wherever the caller points it, from the server's network.
+func testConnectionAllowed() bool {
+	return boolEnv("MSSQL_ALLOW_TEST_CONNECTION")
+}
+
+// testConnection opens a temporary pool for connString, pings it once within
+// MSSQL_CONNECT_TIMEOUT_SECONDS, and reports the server and database it
+// reached. The pool is always closed and never replaces a configured
+// connection. MSSQL_AUTH, MSSQL_APP_NAME and MSSQL_REQUIRE_ENCRYPTION apply as
+// for configured connections. Passwords are redacted from any error.
+func testConnection(ctx context.Context, connString string) (string, error) {
+	if !testConnectionAllowed() {
+		return "", fmt.Errorf("test_connection is disabled: set MSSQL_ALLOW_TEST_CONNECTION=true to allow testing connection strings")
+	}
+	if strings.TrimSpace(connString) == "" {
+		return "", fmt.Errorf("connection string is empty")
+	}
+
+	db, err := openDB(connString)
+	if err != nil {
+		return "", fmt.Errorf("failed to open connection: %s", redactSecrets(err.Error(), connString))
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout())
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return "", fmt.Errorf("connection failed: %s", redactSecrets(err.Error(), connString))
+	}
+
+	var server, database, login string
+	if err := db.QueryRowContext(ctx, "SELECT @@SERVERNAME, DB_NAME(), SUSER_SNAME()").Scan(&server, &database, &login); err != nil {
+		return "", fmt.Errorf("connected, but failed to read server details: %s", redactSecrets(err.Error(), connString))
+	}
+	return fmt.Sprintf("Connection succeeded: server %s, database %s, login %s.", server, database, login), nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultTransactionTimeout = 5 * time.Minute
+
+// transactionTimeout returns how long a transaction may stay open before it
+// is rolled back automatically, from MSSQL_TRANSACTION_TIMEOUT_SECONDS.
+func transactionTimeout() time.Duration {
+	seconds := intEnv("MSSQL_TRANSACTION_TIMEOUT_SECONDS", 0, 1)
+	if seconds == 0 {
+		return defaultTransactionTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// transaction is an open transaction started with begin_transaction. Its
+// context carries the transaction deadline; database/sql rolls the
+// transaction back as soon as that context is done.
+type transaction struct {
+	tx       *sql.Tx
+	ctx      context.Context
+	cancel   context.CancelFunc
+	deadline time.Time
+
+	// mu serializes statements, commit and rollback, since a transaction
+	// runs on a single connection.
+	mu sync.Mutex
+}
+
+// beginTransaction opens a transaction on the named connection and returns
+// the ID further statements, commit and rollback refer to it by. The
+// transaction is rolled back and forgotten if it is still open after
+// MSSQL_TRANSACTION_TIMEOUT_SECONDS or when the server shuts down.
+func (dm *DatabaseManager) beginTransaction(connection string) (string, time.Duration, error) {
+	db, err := dm.getConnection(connection)
+	if err != nil {
+		return "", 0, fmt.Errorf("database connection unavailable: %v", err)
+	}
+
+	timeout := transactionTimeout()
+	ctx, cancel := context.WithTimeout(dm.shutdownCtx, timeout)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return "", 0, wrapQueryError(ctx, timeout, "failed to begin transaction", err)
+	}
+	t := &transaction{tx: tx, ctx: ctx, cancel: cancel, deadline: time.Now().Add(timeout)}
+
+	dm.mu.Lock()
+	dm.nextTransactionID++
+	id := fmt.Sprintf("t%d", dm.nextTransactionID)
+	dm.transactions[id] = t
+	dm.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		dm.mu.Lock()
+		if dm.transactions[id] == t {
+			delete(dm.transactions, id)
+		}
+		dm.mu.Unlock()
+	})
+
+	return id, timeout, nil
+}
+
+// transaction returns the open transaction with the given ID.
+func (dm *DatabaseManager) transaction(id string) (*transaction, error) {
+	dm.mu.RLock()
+	t, ok := dm.transactions[id]
+	dm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no open transaction with ID '%s'; it may have been committed, rolled back, or expired", id)
+	}
+	return t, nil
+}
+
+// endTransaction commits or rolls back the transaction with the given ID and
+// forgets it.
+func (dm *DatabaseManager) endTransaction(id string, commit bool) error {
+	t, err := dm.transaction(id)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if commit {
+		err = t.tx.Commit()
+	} else {
+		err = t.tx.Rollback()
+	}
+	t.cancel()
+
+	if errors.Is(err, sql.ErrTxDone) && errors.Is(t.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("transaction %s was rolled back after exceeding its deadline", id)
+	}
+	if err != nil {
+		action := "rollback"
+		if commit {
+			action = "commit"
+		}
+		return fmt.Errorf("%s failed: %v", action, err)
+	}
+	return nil
+}
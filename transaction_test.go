@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionTimeout(t *testing.T) {
+	t.Setenv("MSSQL_TRANSACTION_TIMEOUT_SECONDS", "")
+	assert.Equal(t, defaultTransactionTimeout, transactionTimeout())
+
+	t.Setenv("MSSQL_TRANSACTION_TIMEOUT_SECONDS", "60")
+	assert.Equal(t, time.Minute, transactionTimeout())
+}
+
+func TestEndUnknownTransaction(t *testing.T) {
+	dm := NewDatabaseManager()
+	assert.ErrorContains(t, dm.endTransaction("t9", true), "no open transaction with ID 't9'")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTransientRetries  = 3
+	transientRetryBaseDelay  = 500 * time.Millisecond
+	transientRetryMaxDelay   = 8 * time.Second
+	defaultRetryableErrorSet = "1204,40197,40501,10928,10929"
+)
+
+// transientRetries returns how many times a query failing with a retryable
+// error is retried, from MSSQL_TRANSIENT_RETRIES. Zero disables retries.
+func transientRetries() int {
+	return intEnv("MSSQL_TRANSIENT_RETRIES", defaultTransientRetries, 0)
+}
+
+// retryableErrors returns the SQL Server error numbers treated as transient:
+// lock memory exhaustion (1204), Azure SQL service errors and throttling
+// (40197, 40501) and resource limits (10928, 10929). MSSQL_RETRYABLE_ERRORS
+// replaces the set with a comma-separated list, and "none" disables it.
+// Entries that are not numbers are ignored.
+func retryableErrors() map[int32]bool {
+	raw, ok := os.LookupEnv("MSSQL_RETRYABLE_ERRORS")
+	if !ok {
+		raw = defaultRetryableErrorSet
+	}
+	if strings.EqualFold(strings.TrimSpace(raw), "none") {
+		return nil
+	}
+
+	numbers := make(map[int32]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		if number, err := strconv.ParseInt(strings.TrimSpace(entry), 10, 32); err == nil {
+			numbers[int32(number)] = true
+		}
+	}
+	return numbers
+}
+
+// transientErrorNumber returns the SQL Server error number of err when it is
+// one of the retryable errors.
+func transientErrorNumber(err error) (int32, bool) {
+	sqlErr, ok := sqlServerError(err)
+	if !ok || !retryableErrors()[sqlErr.Number] {
+		return 0, false
+	}
+	return sqlErr.Number, true
+}
+
+// transientRetryDelay is the exponential backoff before retry attempt, with
+// up to half of it again added as jitter so throttled clients spread out.
+func transientRetryDelay(attempt int) time.Duration {
+	delay := min(transientRetryBaseDelay<<(attempt-1), transientRetryMaxDelay)
+	return delay + rand.N(delay/2+1)
+}
+
+// withTransientRetry wraps run so a transient error, as listed by
+// MSSQL_RETRYABLE_ERRORS, is retried up to MSSQL_TRANSIENT_RETRIES times with
+// exponential backoff. As with deadlocks, only a single read-only SELECT, as
+// decided by isReadOnlyQuery, is retried unless optIn is set; a batch that
+// runs further statements after the SELECT could repeat their side effects.
+func withTransientRetry(query string, optIn bool, run func(ctx context.Context) (string, error)) func(ctx context.Context) (string, error) {
+	if !optIn && !isReadOnlyQuery(query) {
+		return run
+	}
+	return func(ctx context.Context) (string, error) {
+		retries := transientRetries()
+		for attempt := 1; ; attempt++ {
+			result, err := run(ctx)
+			if err == nil || attempt > retries {
+				return result, err
+			}
+			number, ok := transientErrorNumber(err)
+			if !ok {
+				return result, err
+			}
+
+			delay := transientRetryDelay(attempt)
+			fmt.Fprintf(os.Stderr, "Query failed with transient error %d (attempt %d of %d); retrying in %v\n", number, attempt, retries+1, delay.Round(time.Millisecond))
+			select {
+			case <-ctx.Done():
+				return result, err
+			case <-time.After(delay):
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryableErrors(t *testing.T) {
+	assert.Equal(t, map[int32]bool{1204: true, 40197: true, 40501: true, 10928: true, 10929: true}, retryableErrors())
+
+	t.Setenv("MSSQL_RETRYABLE_ERRORS", "40613, abc,49918")
+	assert.Equal(t, map[int32]bool{40613: true, 49918: true}, retryableErrors())
+
+	t.Setenv("MSSQL_RETRYABLE_ERRORS", "none")
+	assert.Empty(t, retryableErrors())
+	_, ok := transientErrorNumber(mssql.Error{Number: 40501})
+	assert.False(t, ok)
+}
+
+func TestTransientRetryDelay(t *testing.T) {
+	for attempt, base := range map[int]time.Duration{1: transientRetryBaseDelay, 2: 2 * transientRetryBaseDelay, 10: transientRetryMaxDelay} {
+		delay := transientRetryDelay(attempt)
+		assert.GreaterOrEqual(t, delay, base)
+		assert.LessOrEqual(t, delay, base+base/2)
+	}
+}
+
+func TestWithTransientRetry(t *testing.T) {
+	t.Setenv("MSSQL_TRANSIENT_RETRIES", "1")
+	throttled := fmt.Errorf("query execution failed: %w", mssql.Error{Number: 40501, Message: "The service is currently busy."})
+
+	calls := 0
+	run := func(ctx context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", throttled
+		}
+		return "ok", nil
+	}
+	result, err := withTransientRetry("SELECT 1", false, run)(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	_, err = withTransientRetry("DELETE FROM t", false, run)(context.Background())
+	assert.ErrorIs(t, err, throttled)
+	assert.Equal(t, 1, calls, "writes are not retried without opting in")
+
+	calls = 0
+	_, err = withTransientRetry("SELECT 1; BACKUP DATABASE x TO DISK='c:\\x.bak'", false, run)(context.Background())
+	assert.ErrorIs(t, err, throttled)
+	assert.Equal(t, 1, calls, "a SELECT followed by another statement is not read-only")
+
+	calls = 0
+	_, err = withTransientRetry("DELETE FROM t", true, run)(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Errors outside the retryable set fail straight away.
+	calls = 0
+	_, err = withTransientRetry("SELECT 1", false, func(ctx context.Context) (string, error) {
+		calls++
+		return "", mssql.Error{Number: 208}
+	})(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
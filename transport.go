@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Transports selectable with MSSQL_TRANSPORT.
+const (
+	transportStdio = "stdio"
+	transportSSE   = "sse"
+	transportHTTP  = "http"
+)
+
+const (
+	defaultHTTPAddr     = ":8080"
+	httpShutdownTimeout = 10 * time.Second
+)
+
+// selectedTransport returns the transport named by MSSQL_TRANSPORT, stdio when
+// it is unset.
+func selectedTransport() string {
+	transport := strings.ToLower(strings.TrimSpace(os.Getenv("MSSQL_TRANSPORT")))
+	if transport == "" {
+		return transportStdio
+	}
+	return transport
+}
+
+// httpTransport is implemented by the mcp-go SSE and streamable HTTP servers.
+type httpTransport interface {
+	Start(addr string) error
+	Shutdown(ctx context.Context) error
+}
+
+// newHTTPTransport builds the SSE or streamable HTTP server for s. Both share a
+// mux that also exposes Prometheus metrics on /metrics, and record each
+// request's source address for the rate limiter.
+func newHTTPTransport(s *server.MCPServer, transport string) httpTransport {
+	httpServer := &http.Server{}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.Handler())
+	httpServer.Handler = mux
+
+	var srv interface {
+		httpTransport
+		http.Handler
+	}
+	if transport == transportSSE {
+		srv = server.NewSSEServer(s, server.WithHTTPServer(httpServer), server.WithSSEContextFunc(rememberClientAddr))
+	} else {
+		srv = server.NewStreamableHTTPServer(s, server.WithStreamableHTTPServer(httpServer), server.WithHTTPContextFunc(rememberClientAddr))
+	}
+	mux.Handle("/", srv)
+	return srv
+}
+
+// serve runs s on the transport selected by MSSQL_TRANSPORT until the client
+// disconnects or the process receives SIGINT or SIGTERM. On a signal, new tool
+// calls are refused and in-flight queries get a grace period to finish before
+// serve returns.
+func serve(s *server.MCPServer, dm *DatabaseManager) error {
+	transport := selectedTransport()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	var srv httpTransport
+	switch transport {
+	case transportStdio:
+		// Listen runs on its own context so a signal does not cancel the
+		// tool calls it is still serving.
+		go func() {
+			errCh <- server.NewStdioServer(s).Listen(context.Background(), os.Stdin, os.Stdout)
+		}()
+	case transportSSE, transportHTTP:
+		srv = newHTTPTransport(s, transport)
+	default:
+		return fmt.Errorf("unsupported MSSQL_TRANSPORT '%s': expected %s, %s or %s", transport, transportStdio, transportSSE, transportHTTP)
+	}
+
+	if srv != nil {
+		addr := strings.TrimSpace(os.Getenv("MSSQL_HTTP_ADDR"))
+		if addr == "" {
+			addr = defaultHTTPAddr
+		}
+		go func() {
+			errCh <- srv.Start(addr)
+		}()
+		fmt.Fprintf(os.Stderr, "Serving MCP over %s on %s\n", transport, addr)
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	dm.drain(shutdownGrace())
+
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// validateTimeout bounds validate_sql, which only parses or compiles and so
+// should never approach the query timeout.
+const validateTimeout = 10 * time.Second
+
+// validateSQL checks query without executing it. By default the batch is only
+// parsed (SET PARSEONLY); with checkNames it is also compiled (SET NOEXEC) so
+// missing tables and columns are reported too.
+func validateSQL(ctx context.Context, dm *DatabaseManager, connection, query string, checkNames bool) (string, error) {
+	if err := checkNoSessionOptionOverride(query); err != nil {
+		return "", err
+	}
+
+	option := "PARSEONLY"
+	if checkNames {
+		option = "NOEXEC"
+	}
+
+	err := withSessionOption(ctx, dm, connection, validateTimeout, option, func(ctx context.Context, conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return wrapQueryError(ctx, validateTimeout, "validation failed", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if checkNames {
+		return "Query is valid: it parsed and compiled without errors.", nil
+	}
+	return "Query is valid: it parsed without errors. Object names were not checked.", nil
+}
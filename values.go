@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// timeLayout is a time of day. The driver returns TIME values on
+	// 0001-01-01, which says nothing about the column.
+	timeLayout = "15:04:05.9999999"
+	// dateTimeLayout is ISO 8601 without a zone, for types like datetime2 that
+	// carry no offset and would be misrepresented by a trailing Z.
+	dateTimeLayout = "2006-01-02T15:04:05.999999999"
+)
+
+// dateTimeFormat returns the Go layout for datetime values, from
+// MSSQL_DATETIME_FORMAT. An empty value keeps the defaults.
+func dateTimeFormat() string {
+	return os.Getenv("MSSQL_DATETIME_FORMAT")
+}
+
+// normalizeValue converts a scanned driver value into the representation used
+// by every output format. dbType is the column's SQL Server type name.
+func normalizeValue(v interface{}, dbType string) interface{} {
+	switch dbType {
+	case "SQL_VARIANT":
+		return normalizeVariant(v)
+	case "XML":
+		// XML arrives as its decoded text; never hex-encode it.
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return v
+	case "UNIQUEIDENTIFIER":
+		return formatGUID(v)
+	}
+
+	switch val := v.(type) {
+	case time.Time:
+		return formatTime(val, dbType)
+	case []byte:
+		if isDecimalType(dbType) {
+			// The driver already renders decimals as exact strings padded to
+			// the declared scale; json.Number keeps them unquoted in JSON
+			// without ever passing through a float.
+			return json.Number(val)
+		}
+		return formatBytes(val, dbType)
+	case float64:
+		if dbType == "REAL" {
+			// REAL is single precision but arrives widened to float64, so
+			// 19.99 would otherwise print as 19.989999771118164.
+			return json.Number(strconv.FormatFloat(val, 'g', -1, 32))
+		}
+	}
+	return v
+}
+
+// variantDecimal matches the exact numeric text the driver produces for
+// decimal, numeric and money values stored in a sql_variant.
+var variantDecimal = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// normalizeVariant renders a sql_variant value according to the type it
+// actually holds, which the driver decodes but does not report. Numbers,
+// strings and bits come through as Go values already. Exact numerics arrive
+// as digit text and become JSON numbers; any other bytes (binary and
+// uniqueidentifier) are shown as 0x hex. Times with an offset keep it.
+func normalizeVariant(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		if variantDecimal.Match(val) {
+			return json.Number(val)
+		}
+		return formatBytes(val, "VARBINARY")
+	case time.Time:
+		if val.Location() != time.UTC {
+			return formatTime(val, "DATETIMEOFFSET")
+		}
+		return formatTime(val, "DATETIME2")
+	}
+	return v
+}
+
+// isDecimalType reports whether dbType is an exact numeric type.
+func isDecimalType(dbType string) bool {
+	switch dbType {
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY":
+		return true
+	}
+	return false
+}
+
+// isNumericType reports whether dbType holds numbers, which text output
+// right-justifies.
+func isNumericType(dbType string) bool {
+	switch dbType {
+	case "TINYINT", "SMALLINT", "INT", "BIGINT", "FLOAT", "REAL":
+		return true
+	}
+	return isDecimalType(dbType)
+}
+
+// isBinaryType reports whether dbType holds raw bytes rather than text.
+// rowversion/timestamp columns are reported by the driver as BINARY.
+func isBinaryType(dbType string) bool {
+	switch dbType {
+	case "BINARY", "VARBINARY", "IMAGE":
+		return true
+	}
+	return false
+}
+
+// formatBytes renders binary columns as 0x-prefixed hex, the notation SQL
+// Server itself uses for binary literals. Other byte values are text the
+// driver left undecoded and are kept as strings when they are valid UTF-8.
+func formatBytes(b []byte, dbType string) string {
+	if !isBinaryType(dbType) && utf8.Valid(b) {
+		return string(b)
+	}
+	return "0x" + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// formatGUID renders a uniqueidentifier as the canonical uppercase hyphenated
+// string SQL Server itself prints. The driver returns the raw bytes, whose
+// first three groups are little-endian, so they cannot be shown as text or
+// plain hex.
+func formatGUID(v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	var id mssql.UniqueIdentifier
+	if err := id.Scan(b); err != nil {
+		return formatBytes(b, "VARBINARY")
+	}
+	return id.String()
+}
+
+// formatTime renders t according to its column type. DATE columns render as
+// a plain date, TIME as a time of day, DATETIMEOFFSET as RFC 3339 with its
+// offset, and the other datetime types as zone-less ISO 8601.
+// MSSQL_DATETIME_FORMAT overrides the layout for everything except DATE and
+// TIME.
+func formatTime(t time.Time, dbType string) string {
+	switch dbType {
+	case "DATE":
+		return t.Format(dateLayout)
+	case "TIME":
+		return t.Format(timeLayout)
+	}
+	if layout := dateTimeFormat(); layout != "" {
+		return t.Format(layout)
+	}
+	if dbType == "DATETIMEOFFSET" {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(dateTimeLayout)
+}
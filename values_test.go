@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	offset := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 2*60*60))
+
+	timeOfDay := time.Date(1, 1, 1, 14, 30, 15, 1234500, time.UTC)
+
+	assert.Equal(t, "2024-01-02", formatTime(ts, "DATE"))
+	assert.Equal(t, "2024-01-02", formatTime(ts.Truncate(24*time.Hour), "DATE"))
+	assert.Equal(t, "14:30:15.0012345", formatTime(timeOfDay, "TIME"))
+	assert.Equal(t, "14:30:15", formatTime(timeOfDay.Truncate(time.Second), "TIME"))
+	assert.Equal(t, "2024-01-02T03:04:05.123", formatTime(ts, "DATETIME2"))
+	assert.Equal(t, "2024-01-02T03:04:05", formatTime(ts.Truncate(time.Second), "DATETIME"))
+	assert.Equal(t, "2024-01-02T03:04:05+02:00", formatTime(offset, "DATETIMEOFFSET"))
+
+	t.Setenv("MSSQL_DATETIME_FORMAT", "2006/01/02 15:04")
+	assert.Equal(t, "2024/01/02 03:04", formatTime(ts, "DATETIME2"))
+	assert.Equal(t, "2024-01-02", formatTime(ts, "DATE"))
+	assert.Equal(t, "14:30:15.0012345", formatTime(timeOfDay, "TIME"))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0x00FF10", formatBytes([]byte{0x00, 0xff, 0x10}, "VARBINARY"))
+	assert.Equal(t, "0x68656C6C6F", formatBytes([]byte("hello"), "BINARY"))
+	assert.Equal(t, "0x", formatBytes([]byte{}, "IMAGE"))
+	assert.Equal(t, "19.99", formatBytes([]byte("19.99"), "DECIMAL"))
+	assert.Equal(t, "0xFFFE", formatBytes([]byte{0xff, 0xfe}, "SQL_VARIANT"))
+}
+
+func TestNormalizeDecimal(t *testing.T) {
+	assert.Equal(t, json.Number("12345678901234567890.1234567890"), normalizeValue([]byte("12345678901234567890.1234567890"), "DECIMAL"))
+	assert.Equal(t, json.Number("19.9900"), normalizeValue([]byte("19.9900"), "MONEY"))
+	assert.Equal(t, json.Number("-0.05"), normalizeValue([]byte("-0.05"), "DECIMAL"))
+	assert.Equal(t, json.Number("19.99"), normalizeValue(float64(float32(19.99)), "REAL"))
+	assert.Equal(t, 19.99, normalizeValue(19.99, "FLOAT"))
+
+	out, err := formatJSONRows([]string{"price"}, [][]interface{}{{normalizeValue([]byte("19.9900"), "MONEY")}})
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"price":19.9900}]`, out)
+	assert.Equal(t, "19.9900", formatValue(normalizeValue([]byte("19.9900"), "MONEY")))
+}
+
+func TestNormalizeVariantAndXML(t *testing.T) {
+	assert.Equal(t, json.Number("19.9900"), normalizeValue([]byte("19.9900"), "SQL_VARIANT"))
+	assert.Equal(t, "0x00FF10", normalizeValue([]byte{0x00, 0xff, 0x10}, "SQL_VARIANT"))
+	assert.Equal(t, "0x6869", normalizeValue([]byte("hi"), "SQL_VARIANT"))
+	assert.Equal(t, "text", normalizeValue("text", "SQL_VARIANT"))
+	assert.Equal(t, int64(42), normalizeValue(int64(42), "SQL_VARIANT"))
+	assert.Nil(t, normalizeValue(nil, "SQL_VARIANT"))
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, "2024-01-02T03:04:05", normalizeValue(ts, "SQL_VARIANT"))
+	assert.Equal(t, "2024-01-02T05:04:05+02:00", normalizeValue(ts.In(time.FixedZone("", 2*60*60)), "SQL_VARIANT"))
+
+	assert.Equal(t, "<a><b>1</b></a>", normalizeValue("<a><b>1</b></a>", "XML"))
+	assert.Equal(t, "<a/>", normalizeValue([]byte("<a/>"), "XML"))
+}
+
+func TestNormalizeGUID(t *testing.T) {
+	// The wire form of 6F9619FF-8B86-D011-B42D-00C04FC964FF.
+	raw := []byte{0xFF, 0x19, 0x96, 0x6F, 0x86, 0x8B, 0x11, 0xD0, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}
+	assert.Equal(t, "6F9619FF-8B86-D011-B42D-00C04FC964FF", normalizeValue(raw, "UNIQUEIDENTIFIER"))
+	assert.Nil(t, normalizeValue(nil, "UNIQUEIDENTIFIER"))
+	assert.Equal(t, "0x0102", normalizeValue([]byte{0x01, 0x02}, "UNIQUEIDENTIFIER"))
+}
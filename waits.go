@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxReportedWaits caps how many wait types include_waits lists, longest
+// first.
+const maxReportedWaits = 10
+
+// sessionCountersQuery reads the current session's cumulative CPU and I/O
+// counters and its per-wait-type totals. Every session can see its own rows
+// without VIEW SERVER STATE.
+const sessionCountersQuery = `SELECT cpu_time, logical_reads, reads, writes
+FROM sys.dm_exec_sessions
+WHERE session_id = @@SPID;
+SELECT wait_type, waiting_tasks_count, wait_time_ms
+FROM sys.dm_exec_session_wait_stats
+WHERE session_id = @@SPID`
+
+// waitCounter is one wait type's totals for a session.
+type waitCounter struct {
+	tasks int64
+	ms    int64
+}
+
+// sessionCounters is a snapshot of a session's cumulative resource use.
+type sessionCounters struct {
+	cpuMs        int64
+	logicalReads int64
+	reads        int64
+	writes       int64
+	waits        map[string]waitCounter
+}
+
+// sessionWait is the time one wait type added while a query ran.
+type sessionWait struct {
+	waitType string
+	tasks    int64
+	ms       int64
+}
+
+// sessionActivity is the difference between the counters taken before and
+// after a query: the CPU, I/O and waits the query itself caused.
+type sessionActivity struct {
+	cpuMs        int64
+	logicalReads int64
+	reads        int64
+	writes       int64
+	waits        []sessionWait
+}
+
+// rowQuerier is what a query can run on: a pool, a reserved connection or a
+// transaction.
+type rowQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readSessionCounters snapshots the counters of the session querier runs on.
+func readSessionCounters(ctx context.Context, querier rowQuerier) (*sessionCounters, error) {
+	rows, err := querier.QueryContext(ctx, sessionCountersQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := &sessionCounters{waits: make(map[string]waitCounter)}
+	if rows.Next() {
+		if err := rows.Scan(&counters.cpuMs, &counters.logicalReads, &counters.reads, &counters.writes); err != nil {
+			return nil, err
+		}
+	}
+	if rows.NextResultSet() {
+		for rows.Next() {
+			var waitType string
+			var wait waitCounter
+			if err := rows.Scan(&waitType, &wait.tasks, &wait.ms); err != nil {
+				return nil, err
+			}
+			counters.waits[waitType] = wait
+		}
+	}
+	return counters, rows.Err()
+}
+
+// sessionActivityBetween subtracts before from after. Only wait types that
+// grew are kept, longest first, at most maxReportedWaits of them.
+func sessionActivityBetween(before, after *sessionCounters) *sessionActivity {
+	activity := &sessionActivity{
+		cpuMs:        after.cpuMs - before.cpuMs,
+		logicalReads: after.logicalReads - before.logicalReads,
+		reads:        after.reads - before.reads,
+		writes:       after.writes - before.writes,
+	}
+	for waitType, wait := range after.waits {
+		prior := before.waits[waitType]
+		if wait.tasks > prior.tasks || wait.ms > prior.ms {
+			activity.waits = append(activity.waits, sessionWait{waitType: waitType, tasks: wait.tasks - prior.tasks, ms: wait.ms - prior.ms})
+		}
+	}
+	sort.Slice(activity.waits, func(i, j int) bool {
+		if activity.waits[i].ms != activity.waits[j].ms {
+			return activity.waits[i].ms > activity.waits[j].ms
+		}
+		return activity.waits[i].waitType < activity.waits[j].waitType
+	})
+	if len(activity.waits) > maxReportedWaits {
+		activity.waits = activity.waits[:maxReportedWaits]
+	}
+	return activity
+}
+
+// line summarizes the activity for the top of text output, e.g.
+// "-- cpu 12ms, 340 logical reads, 2 physical reads, 0 writes; waits: PAGEIOLATCH_SH 8ms (3 tasks) --".
+func (a *sessionActivity) line() string {
+	waits := "none"
+	if len(a.waits) > 0 {
+		parts := make([]string, len(a.waits))
+		for i, wait := range a.waits {
+			parts[i] = fmt.Sprintf("%s %dms (%d tasks)", wait.waitType, wait.ms, wait.tasks)
+		}
+		waits = strings.Join(parts, ", ")
+	}
+	return fmt.Sprintf("-- cpu %dms, %d logical reads, %d physical reads, %d writes; waits: %s --\n", a.cpuMs, a.logicalReads, a.reads, a.writes, waits)
+}
+
+// json renders the activity as the "session_stats" metadata object.
+func (a *sessionActivity) json() string {
+	type wait struct {
+		WaitType     string `json:"wait_type"`
+		WaitingTasks int64  `json:"waiting_tasks"`
+		WaitTimeMs   int64  `json:"wait_time_ms"`
+	}
+	waits := make([]wait, len(a.waits))
+	for i, w := range a.waits {
+		waits[i] = wait{w.waitType, w.tasks, w.ms}
+	}
+	b, _ := json.Marshal(struct {
+		CPUMs         int64  `json:"cpu_ms"`
+		LogicalReads  int64  `json:"logical_reads"`
+		PhysicalReads int64  `json:"physical_reads"`
+		Writes        int64  `json:"writes"`
+		Waits         []wait `json:"waits"`
+	}{a.cpuMs, a.logicalReads, a.reads, a.writes, waits})
+	return string(b)
+}
+
+// withSessionActivity runs fn on one connection with opts pinned to it,
+// snapshotting the session's counters before and after so the query's own
+// CPU, I/O and waits can be reported. A transaction or reserved connection
+// in opts is used as is; otherwise a connection is reserved from the pool,
+// switched to opts.database when that is set. The activity is nil, with the
+// reason in note, if the counters could not be read after the query.
+func withSessionActivity(ctx context.Context, dm *DatabaseManager, opts queryOptions, fn func(opts queryOptions) error) (activity *sessionActivity, note string, err error) {
+	var querier rowQuerier
+	switch {
+	case opts.tx != nil:
+		querier = opts.tx.tx
+	case opts.conn != nil:
+		querier = opts.conn
+	default:
+		db, err := dm.getConnection(opts.connection)
+		if err != nil {
+			return nil, "", fmt.Errorf("database connection unavailable: %v", err)
+		}
+		var conn *sql.Conn
+		if opts.database != "" {
+			var release func()
+			if conn, release, err = useDatabase(ctx, db, opts.database); err != nil {
+				return nil, "", fmt.Errorf("database switch failed: %v", err)
+			}
+			defer release()
+		} else {
+			if conn, err = db.Conn(ctx); err != nil {
+				return nil, "", fmt.Errorf("failed to reserve a connection: %v", err)
+			}
+			defer conn.Close()
+		}
+		opts.conn, opts.database = conn, ""
+		querier = conn
+	}
+
+	snapshot := func() (*sessionCounters, error) {
+		if opts.tx != nil {
+			opts.tx.mu.Lock()
+			defer opts.tx.mu.Unlock()
+		}
+		return readSessionCounters(ctx, querier)
+	}
+	before, err := snapshot()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read session statistics: %v", err)
+	}
+	if err := fn(opts); err != nil {
+		return nil, "", err
+	}
+	after, err := snapshot()
+	if err != nil {
+		return nil, fmt.Sprintf("\n(session statistics could not be read after the query: %v)", err), nil
+	}
+	return sessionActivityBetween(before, after), "", nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionActivityBetween(t *testing.T) {
+	before := &sessionCounters{cpuMs: 100, logicalReads: 50, reads: 5, writes: 1, waits: map[string]waitCounter{
+		"PAGEIOLATCH_SH":   {tasks: 10, ms: 40},
+		"ASYNC_NETWORK_IO": {tasks: 2, ms: 3},
+	}}
+	after := &sessionCounters{cpuMs: 112, logicalReads: 390, reads: 7, writes: 1, waits: map[string]waitCounter{
+		"PAGEIOLATCH_SH":   {tasks: 13, ms: 48},
+		"ASYNC_NETWORK_IO": {tasks: 2, ms: 3},
+		"LCK_M_S":          {tasks: 1, ms: 20},
+	}}
+
+	activity := sessionActivityBetween(before, after)
+	assert.Equal(t, &sessionActivity{cpuMs: 12, logicalReads: 340, reads: 2, writes: 0, waits: []sessionWait{
+		{waitType: "LCK_M_S", tasks: 1, ms: 20},
+		{waitType: "PAGEIOLATCH_SH", tasks: 3, ms: 8},
+	}}, activity)
+	assert.Equal(t, "-- cpu 12ms, 340 logical reads, 2 physical reads, 0 writes; waits: LCK_M_S 20ms (1 tasks), PAGEIOLATCH_SH 8ms (3 tasks) --\n", activity.line())
+	assert.Equal(t, `{"cpu_ms":12,"logical_reads":340,"physical_reads":2,"writes":0,"waits":[{"wait_type":"LCK_M_S","waiting_tasks":1,"wait_time_ms":20},{"wait_type":"PAGEIOLATCH_SH","waiting_tasks":3,"wait_time_ms":8}]}`, activity.json())
+
+	idle := sessionActivityBetween(after, after)
+	assert.Equal(t, "-- cpu 0ms, 0 logical reads, 0 physical reads, 0 writes; waits: none --\n", idle.line())
+	assert.Equal(t, `{"cpu_ms":0,"logical_reads":0,"physical_reads":0,"writes":0,"waits":[]}`, idle.json())
+}
+
+func TestSessionActivityBetweenCapsWaits(t *testing.T) {
+	before := &sessionCounters{waits: map[string]waitCounter{}}
+	after := &sessionCounters{waits: map[string]waitCounter{}}
+	for i := 1; i <= maxReportedWaits+5; i++ {
+		after.waits[fmt.Sprintf("WAIT_%02d", i)] = waitCounter{tasks: 1, ms: int64(i)}
+	}
+
+	activity := sessionActivityBetween(before, after)
+	assert.Len(t, activity.waits, maxReportedWaits)
+	assert.Equal(t, "WAIT_15", activity.waits[0].waitType)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// warmUpConnection opens the default connection pool in the background when
+// MSSQL_WARMUP is set, so the first query does not pay for connection setup.
+// getConnection opens each pool under its own lock, so a request that arrives
+// while the warmup is still connecting waits for the same pool instead of
+// opening a second one. A failure is only logged; the connection is retried
+// lazily as usual. The returned channel is closed when the warmup finishes,
+// and is nil when it is disabled.
+func warmUpConnection(dm *DatabaseManager) <-chan struct{} {
+	if !boolEnv("MSSQL_WARMUP") {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := dm.getConnection(""); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: connection warmup failed: %v\n", err)
+		}
+	}()
+	return done
+}
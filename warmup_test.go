@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmUpConnection(t *testing.T) {
+	t.Setenv("MSSQL_WARMUP", "")
+	assert.Nil(t, warmUpConnection(NewDatabaseManager()))
+
+	// A failed warmup must not stop the server; the error is only logged.
+	t.Setenv("MSSQL_WARMUP", "true")
+	t.Setenv("MSSQL_CONNECTION_STRING_FILE", "")
+	t.Setenv("MSSQL_CONNECTION_STRING", "")
+	t.Setenv("MSSQL_HOST", "")
+	dm := NewDatabaseManager()
+	done := warmUpConnection(dm)
+	require.NotNil(t, done)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("warmup did not finish")
+	}
+	_, err := dm.getConnection("")
+	assert.ErrorContains(t, err, "MSSQL_CONNECTION_STRING environment variable is not set")
+}
+
+func TestGetConnectionReleasesLockWhileConnecting(t *testing.T) {
+	// A server that accepts connections but never answers the login keeps
+	// the ping waiting until it hangs up.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	t.Setenv("MSSQL_CONNECTION_STRING_FILE", "")
+	t.Setenv("MSSQL_CONNECTION_STRING", "sqlserver://sa:secret@"+listener.Addr().String()+"?encrypt=disable")
+	t.Setenv("MSSQL_CONNECT_RETRIES", "0")
+	t.Setenv("MSSQL_CONNECT_TIMEOUT_SECONDS", "2")
+	dm := NewDatabaseManager()
+	failed := make(chan error, 1)
+	go func() {
+		_, err := dm.getConnection("")
+		failed <- err
+	}()
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("getConnection did not dial the server")
+	}
+	locked := make(chan struct{})
+	go func() {
+		dm.mu.Lock()
+		dm.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("dm.mu is held while the ping is waiting")
+	}
+
+	// Hanging up fails the login and the attempt is recorded.
+	conn.Close()
+	select {
+	case err := <-failed:
+		assert.ErrorContains(t, err, "failed to connect to database")
+	case <-time.After(5 * time.Second):
+		t.Fatal("getConnection did not return after the server hung up")
+	}
+	assert.Equal(t, 1, dm.pools[""].breaker.failures)
+}